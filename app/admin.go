@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	setupInternal "gitee.com/kelvins-io/kelvins/internal/setup"
+	"gitee.com/kelvins-io/kelvins/util/kprocess"
+)
+
+var loggerLevels = []string{"debug", "info", "warn", "error"}
+
+// adminComponent runs the admin http server of section kelvins-admin on its own port,
+// it is appended to every application and does nothing if the section has no Port.
+type adminComponent struct {
+	app    *kelvins.Application
+	server *http.Server
+	ln     net.Listener
+}
+
+func (c *adminComponent) name() string {
+	return "adminServer"
+}
+
+func (c *adminComponent) application() *kelvins.Application {
+	return c.app
+}
+
+func (c *adminComponent) prepare(kp *kprocess.KProcess) error {
+	adminSetting := kelvins.AdminSetting
+	if !adminSetting.Enabled() {
+		return nil
+	}
+	mux := setupInternal.NewAdminServerMux(adminSetting.PProfEnable)
+	mux.HandleFunc("/debug/logger-level", c.loggerLevelHandler)
+	var handler http.Handler = mux
+	if adminSetting.BasicAuthUser != "" {
+		handler = adminBasicAuth(handler, adminSetting.BasicAuthUser, adminSetting.BasicAuthPassword)
+	}
+	c.server = &http.Server{Handler: handler}
+	return nil
+}
+
+func (c *adminComponent) register() error {
+	return nil
+}
+
+func (c *adminComponent) listen(kp *kprocess.KProcess) (err error) {
+	if c.server == nil {
+		return nil
+	}
+	addr := net.JoinHostPort(kelvins.AdminSetting.GetHost(), fmt.Sprintf("%d", kelvins.AdminSetting.Port))
+	c.ln, err = kp.ListenAddr("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("kprocess listen(%s) pidFile(%v) err: %v", addr, kelvins.PIDFile, err)
+	}
+	logging.Infof("adminServer listen(%s) \n", addr)
+	return nil
+}
+
+// serve serves the admin server, it does not call done since the application does not depend on it.
+func (c *adminComponent) serve(done func()) error {
+	if c.server == nil {
+		return nil
+	}
+	go func() {
+		err := c.server.Serve(c.ln)
+		if err != nil && err != http.ErrServerClosed {
+			logging.Infof("adminServer serve err: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+func (c *adminComponent) drain() {}
+
+func (c *adminComponent) stop(ctx context.Context) error {
+	if c.server == nil {
+		return nil
+	}
+	err := c.server.Shutdown(ctx)
+	if err != nil {
+		c.server.Close()
+		return fmt.Errorf("adminServer Shutdown err: %v, force close", err)
+	}
+	return nil
+}
+
+// loggerLevelHandler returns the logger level on GET, and changes it by the form value level on PUT or POST.
+func (c *adminComponent) loggerLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level := strings.ToLower(r.FormValue("level"))
+		valid := false
+		for _, l := range loggerLevels {
+			if l == level {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			http.Error(w, fmt.Sprintf("level must be one of %v", loggerLevels), http.StatusBadRequest)
+			return
+		}
+		if err := setLoggerLevel(c.app, level); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	loggerLevelMutex.Lock()
+	level := c.app.LoggerLevel
+	loggerLevelMutex.Unlock()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]string{"level": level})
+}
+
+// adminBasicAuth authenticates every path except the health checks which are probed without credentials.
+func adminBasicAuth(handler http.Handler, user, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		u, p, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kelvins-admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -5,28 +5,27 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"math/big"
 	"net"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"gitee.com/kelvins-io/common/event"
 	"gitee.com/kelvins-io/common/log"
+	"gitee.com/kelvins-io/common/queue"
 	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/config/setting"
 	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
-	"gitee.com/kelvins-io/kelvins/internal/service/slb"
-	"gitee.com/kelvins-io/kelvins/internal/service/slb/etcdconfig"
 	"gitee.com/kelvins-io/kelvins/internal/util"
 	"gitee.com/kelvins-io/kelvins/internal/vars"
 	"gitee.com/kelvins-io/kelvins/setup"
-	"gitee.com/kelvins-io/kelvins/util/goroutine"
+	"gitee.com/kelvins-io/kelvins/util/kprocess"
+	"gitee.com/kelvins-io/kelvins/util/registry"
 	"gitee.com/kelvins-io/kelvins/util/startup"
 )
 
@@ -38,15 +37,19 @@ const (
 var (
 	flagLoggerLevel = flag.String("logger_level", "", "set logger level eg: debug,warn,error,info")
 	flagLoggerPath  = flag.String("logger_path", "", "set logger root path eg: /tmp/kelvins-app")
-	flagEnv         = flag.String("env", "", "set exec environment eg: dev,test,prod")
 )
 
 func initApplication(application *kelvins.Application) error {
 	// 1 show app version
 	showAppVersion(application)
 
+	err := runHooks(vars.AppContext, kelvins.HookBeforeInit)
+	if err != nil {
+		return err
+	}
+
 	// 2. load app config
-	err := config.LoadDefaultConfig(application)
+	err = config.LoadDefaultConfig(application)
 	if err != nil {
 		return err
 	}
@@ -55,7 +58,7 @@ func initApplication(application *kelvins.Application) error {
 	setupApplicationProcess(application)
 
 	// 4 startup control
-	next, err := startUpControl(kelvins.PIDFile)
+	next, err := startUpControl(application)
 	if err != nil {
 		return err
 	}
@@ -72,6 +75,11 @@ func initApplication(application *kelvins.Application) error {
 			return err
 		}
 	}
+	// report every config problem at once
+	err = config.Check()
+	if err != nil {
+		return err
+	}
 
 	// 6 init system vars
 	kelvins.AppName = application.Name
@@ -97,6 +105,8 @@ func initApplication(application *kelvins.Application) error {
 	}
 	application.LoggerRootPath = loggerPath
 
+	// a level set by code or flag is not changed by config reload
+	loggerLevelPinned = application.LoggerLevel != "" || *flagLoggerLevel != ""
 	loggerLevel := DefaultLoggerLevel
 	if kelvins.LoggerSetting != nil && kelvins.LoggerSetting.Level != "" {
 		loggerLevel = kelvins.LoggerSetting.Level
@@ -117,8 +127,8 @@ func initApplication(application *kelvins.Application) error {
 	if application.Environment != "" {
 		environment = application.Environment
 	}
-	if *flagEnv != "" {
-		environment = *flagEnv
+	if config.SelectedEnvironment() != "" {
+		environment = config.SelectedEnvironment()
 	}
 	application.Environment = environment
 
@@ -141,6 +151,13 @@ func initApplication(application *kelvins.Application) error {
 			return fmt.Errorf("application.SetupVars err: %v", err)
 		}
 	}
+	err = runHooks(vars.AppContext, kelvins.HookAfterSetupVars)
+	if err != nil {
+		return err
+	}
+
+	// 9. watch config
+	setupConfigReload(application)
 	return nil
 }
 
@@ -164,70 +181,19 @@ func setupApplicationProcess(application *kelvins.Application) {
 
 // setupCommonVars setup application global vars.
 func setupCommonVars(application *kelvins.Application) error {
-	var err error
-	if kelvins.MysqlSetting != nil && kelvins.MysqlSetting.Host != "" {
-		kelvins.MysqlSetting.LoggerLevel = application.LoggerLevel
-		kelvins.MysqlSetting.Environment = application.Environment
-		logger, err := log.GetCustomLogger("db-log", "mysql")
-		if err != nil {
-			return err
-		}
-		kelvins.MysqlSetting.Logger = logger
-		kelvins.GORM_DBEngine, err = setup.NewMySQLWithGORM(kelvins.MysqlSetting)
-		kelvins.XORM_DBEngine, err = setup.NewMySQLWithXORM(kelvins.MysqlSetting)
-		if err != nil {
-			return err
-		}
-	}
-
-	if kelvins.MongoDBSetting != nil && kelvins.MongoDBSetting.Uri != "" {
-		kelvins.MongoDBClient, err = setup.NewMongoDBClient(kelvins.MongoDBSetting)
-		if err != nil {
-			return err
-		}
-	}
+	setupRegistry()
 
-	if kelvins.RedisSetting != nil && kelvins.RedisSetting.Host != "" {
-		kelvins.RedisConn, err = setup.NewRedis(kelvins.RedisSetting)
-		if err != nil {
-			return err
-		}
-	}
-
-	if kelvins.GPoolSetting != nil && kelvins.GPoolSetting.JobChanLen > 0 && kelvins.GPoolSetting.WorkerNum > 0 {
-		kelvins.GPool = goroutine.NewPool(kelvins.GPoolSetting.WorkerNum, kelvins.GPoolSetting.JobChanLen)
-	}
-
-	if kelvins.G2CacheSetting != nil && kelvins.G2CacheSetting.RedisConfDSN != "" {
-		kelvins.G2CacheEngine, err = setup.NewG2Cache(kelvins.G2CacheSetting, nil, nil)
-		if err != nil {
-			return err
-		}
-	}
-
-	kelvins.FrameworkLogger, err = log.GetCustomLogger("framework", "framework")
+	// mysql mongodb redis gpool g2cache and the user resources registered before
+	registerFrameworkResources(application)
+	err := initResources()
 	if err != nil {
 		return err
 	}
-	vars.FrameworkLogger = kelvins.FrameworkLogger
 
-	kelvins.ErrLogger, err = log.GetErrLogger("err")
+	err = setupLoggers()
 	if err != nil {
 		return err
 	}
-	vars.ErrLogger = kelvins.ErrLogger
-
-	kelvins.BusinessLogger, err = log.GetBusinessLogger("business")
-	if err != nil {
-		return err
-	}
-	vars.BusinessLogger = kelvins.BusinessLogger
-
-	kelvins.AccessLogger, err = log.GetAccessLogger("access")
-	if err != nil {
-		return err
-	}
-	vars.AccessLogger = kelvins.AccessLogger
 
 	// init event server
 	if kelvins.AliRocketMQSetting != nil && kelvins.AliRocketMQSetting.InstanceId != "" {
@@ -260,92 +226,222 @@ func setupCommonVars(application *kelvins.Application) error {
 	return nil
 }
 
+// setupLoggers setup application global loggers, it is called again when the logger level is reloaded.
+// the globals are assigned once, a reload swaps the loggers inside them so they are never written while in use.
+func setupLoggers() error {
+	frameworkLogger, err := log.GetCustomLogger("framework", "framework")
+	if err != nil {
+		return err
+	}
+	errLogger, err := log.GetErrLogger("err")
+	if err != nil {
+		return err
+	}
+	businessLogger, err := log.GetBusinessLogger("business")
+	if err != nil {
+		return err
+	}
+	accessLogger, err := log.GetAccessLogger("access")
+	if err != nil {
+		return err
+	}
+
+	setLogger(&kelvins.FrameworkLogger, &vars.FrameworkLogger, frameworkLogger)
+	setLogger(&kelvins.ErrLogger, &vars.ErrLogger, errLogger)
+	setLogger(&kelvins.BusinessLogger, &vars.BusinessLogger, businessLogger)
+	setLogger(&kelvins.AccessLogger, &vars.AccessLogger, accessLogger)
+	return nil
+}
+
+// setLogger swaps next into the reloadableLogger of global, or assigns a new one to global and internal on the first call.
+func setLogger(global, internal *log.LoggerContextIface, next log.LoggerContextIface) {
+	if l, ok := (*global).(*reloadableLogger); ok {
+		l.current.Store(loggerHolder{next})
+		return
+	}
+	l := &reloadableLogger{LoggerContextIface: next}
+	l.current.Store(loggerHolder{next})
+	*global = l
+	*internal = l
+}
+
+// loggerHolder keeps the type stored in atomic.Value the same whatever the logger implementation is.
+type loggerHolder struct {
+	log.LoggerContextIface
+}
+
+// reloadableLogger delegates to the logger created by the last setupLoggers,
+// the methods not overridden here are served by the logger created at startup.
+type reloadableLogger struct {
+	log.LoggerContextIface
+	current atomic.Value // loggerHolder
+}
+
+func (l *reloadableLogger) logger() log.LoggerContextIface {
+	return l.current.Load().(loggerHolder).LoggerContextIface
+}
+
+func (l *reloadableLogger) Debug(ctx context.Context, args ...interface{}) {
+	l.logger().Debug(ctx, args...)
+}
+
+func (l *reloadableLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	l.logger().Debugf(ctx, format, args...)
+}
+
+func (l *reloadableLogger) Info(ctx context.Context, args ...interface{}) {
+	l.logger().Info(ctx, args...)
+}
+
+func (l *reloadableLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	l.logger().Infof(ctx, format, args...)
+}
+
+func (l *reloadableLogger) Warn(ctx context.Context, args ...interface{}) {
+	l.logger().Warn(ctx, args...)
+}
+
+func (l *reloadableLogger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.logger().Warnf(ctx, format, args...)
+}
+
+func (l *reloadableLogger) Error(ctx context.Context, args ...interface{}) {
+	l.logger().Error(ctx, args...)
+}
+
+func (l *reloadableLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.logger().Errorf(ctx, format, args...)
+}
+
+// setupCommonQueue creates the queue servers, a server created by another application of RunComposite
+// is reused and namedTaskFunc is registered on it.
 func setupCommonQueue(namedTaskFunc map[string]interface{}) error {
 	if kelvins.QueueRedisSetting != nil && kelvins.QueueRedisSetting.Broker != "" {
-		queueServ, err := setup.NewRedisQueue(kelvins.QueueRedisSetting, namedTaskFunc)
-		if err != nil {
-			return err
+		if kelvins.QueueServerRedis != nil {
+			if err := registerQueueTasks(kelvins.QueueServerRedis, namedTaskFunc); err != nil {
+				return err
+			}
+		} else {
+			queueServ, err := setup.NewRedisQueue(kelvins.QueueRedisSetting, namedTaskFunc)
+			if err != nil {
+				return err
+			}
+			kelvins.QueueServerRedis = queueServ
+			registerQueueResource(config.SectionQueueRedis, kelvins.QueueRedisSetting.Broker, "6379")
 		}
-		kelvins.QueueServerRedis = queueServ
 	}
 	if kelvins.QueueAMQPSetting != nil && kelvins.QueueAMQPSetting.Broker != "" {
-		queueServ, err := setup.NewAMQPQueue(kelvins.QueueAMQPSetting, namedTaskFunc)
-		if err != nil {
-			return err
+		if kelvins.QueueServerAMQP != nil {
+			if err := registerQueueTasks(kelvins.QueueServerAMQP, namedTaskFunc); err != nil {
+				return err
+			}
+		} else {
+			queueServ, err := setup.NewAMQPQueue(kelvins.QueueAMQPSetting, namedTaskFunc)
+			if err != nil {
+				return err
+			}
+			kelvins.QueueServerAMQP = queueServ
+			registerQueueResource(config.SectionQueueAMQP, kelvins.QueueAMQPSetting.Broker, "5672")
 		}
-		kelvins.QueueServerAMQP = queueServ
 	}
 	if kelvins.QueueAliAMQPSetting != nil && kelvins.QueueAliAMQPSetting.VHost != "" {
-		queueServ, err := setup.NewAliAMQPQueue(kelvins.QueueAliAMQPSetting, namedTaskFunc)
-		if err != nil {
-			return err
+		if kelvins.QueueServerAliAMQP != nil {
+			if err := registerQueueTasks(kelvins.QueueServerAliAMQP, namedTaskFunc); err != nil {
+				return err
+			}
+		} else {
+			queueServ, err := setup.NewAliAMQPQueue(kelvins.QueueAliAMQPSetting, namedTaskFunc)
+			if err != nil {
+				return err
+			}
+			kelvins.QueueServerAliAMQP = queueServ
+			registerQueueResource(config.SectionQueueAliAMQP, "amqp://"+kelvins.QueueAliAMQPSetting.EndPoint, "5672")
 		}
-		kelvins.QueueServerAliAMQP = queueServ
 	}
 
 	return nil
 }
 
+func registerQueueTasks(queueServ *queue.MachineryQueue, namedTaskFunc map[string]interface{}) error {
+	if len(namedTaskFunc) == 0 {
+		return nil
+	}
+	err := queueServ.TaskServer.RegisterTasks(namedTaskFunc)
+	if err != nil {
+		return fmt.Errorf("queue RegisterTasks err: %v", err)
+	}
+	return nil
+}
+
 // appCloseChOne is appCloseCh sync.Once
 var appCloseChOne sync.Once
 var appCloseCh = make(chan struct{})
 
-func appShutdown(application *kelvins.Application) error {
+// appShutdown drains and stops components in order then stops the plugins and closes the resources,
+// every step runs even if one fails and the combined error is returned.
+// ctx carries the force exit deadline, the app context is cancelled during the shutdown so it is not used.
+func appShutdown(ctx context.Context, application *kelvins.Application, components ...component) error {
 	if !appProcessNext {
 		return nil
 	}
+	var errs []error
+	shutdownDrain(ctx, components)
+	if err := runHooks(ctx, kelvins.HookBeforeShutdown); err != nil {
+		errs = append(errs, err)
+	}
+	// the running work observes kelvins.AppContext or kelvins.AppCloseCh and stops within the grace timeout
+	vars.AppCancel()
 	appCloseChOne.Do(func() {
 		close(appCloseCh)
 	})
+	errs = append(errs, shutdownStop(ctx, components)...)
 	if application.StopFunc != nil {
-		err := application.StopFunc()
-		if err != nil {
-			return err
+		if err := application.StopFunc(); err != nil {
+			errs = append(errs, fmt.Errorf("StopFunc err: %v", err))
 		}
 	}
-	if kelvins.GPool != nil {
-		kelvins.GPool.Release()
-		kelvins.GPool.WaitAll()
+	errs = append(errs, stopPlugins(ctx)...)
+	errs = append(errs, closeResources(ctx)...)
+	// the etcd watchers stop on appCloseCh, the shared client is closed after they exit
+	if err := util.CloseEtcdV3(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("close etcd client err: %v", err))
 	}
-	if kelvins.RedisConn != nil {
-		err := kelvins.RedisConn.Close()
-		if err != nil {
-			return err
-		}
-	}
-	if kelvins.GORM_DBEngine != nil {
-		err := kelvins.GORM_DBEngine.Close()
-		if err != nil {
-			return err
-		}
-	}
-	if kelvins.MongoDBClient != nil {
-		err := kelvins.MongoDBClient.Close(context.Background())
-		if err != nil {
-			return err
-		}
+	if err := runHooks(ctx, kelvins.HookAfterShutdown); err != nil {
+		errs = append(errs, err)
 	}
 
-	return nil
+	return combineErrors(errs)
 }
 
-func appPrepareForceExit() {
+// appPrepareForceExit starts the shutdown deadline before the first shutdown step,
+// the returned context expires at the deadline and is passed to every shutdown step.
+func appPrepareForceExit() (context.Context, context.CancelFunc) {
 	// Make sure to set a deadline on exiting the process
 	// after upg.Exit() is closed. No new upgrades can be
 	// performed if the parent doesn't exit.
 	if !appProcessNext {
-		return
+		return context.WithCancel(context.Background())
 	}
-	time.AfterFunc(30*time.Second, func() {
-		logging.Info("App Graceful shutdown timed out, force exit")
+	serverSetting := kelvins.ServerSetting
+	timeout := serverSetting.GetShutdownDrain() + serverSetting.GetShutdownGrace() + serverSetting.GetShutdownForce()
+	time.AfterFunc(timeout, func() {
+		logInflight(fmt.Sprintf("Graceful shutdown timed out %v, force exit", timeout))
 		os.Exit(1)
 	})
+	return context.WithTimeout(context.Background(), timeout)
 }
 
 var appProcessNext bool
 
-func startUpControl(pidFile string) (next bool, err error) {
-	next, err = startup.ParseCliCommand(pidFile)
+func startUpControl(application *kelvins.Application) (next bool, err error) {
+	// -s config prints the user sections too
+	if startup.IsConfigCommand() && application.LoadConfig != nil {
+		err = application.LoadConfig()
+		if err != nil {
+			return
+		}
+	}
+	next, err = startup.ParseCliCommand(kelvins.PIDFile)
 	if next {
 		appProcessNext = true
 	}
@@ -436,105 +532,194 @@ func appRegisterEventHandler(register func(event.EventServerIface) error, appTyp
 	}
 }
 
-func appUnRegisterServiceToEtcd(appName string, port int64) error {
-	etcdServerUrls := config.GetEtcdV3ServerURLs()
-	if etcdServerUrls == "" {
-		if kelvins.ErrLogger != nil {
-			kelvins.ErrLogger.Errorf(context.TODO(), "etcd not found environment variable(%v)", config.ENV_ETCDV3_SERVER_URLS)
-		}
-		return fmt.Errorf("etcd not found environment variable(%v)", config.ENV_ETCDV3_SERVER_URLS)
+// eventHandlerCtx adapts RegisterEventHandlerCtx to appRegisterEventHandler, it is called with the app context
+// and the handlers it registers receive contexts derived from the app context.
+func eventHandlerCtx(register func(context.Context, event.EventServerIface) error) func(event.EventServerIface) error {
+	return func(server event.EventServerIface) error {
+		return register(vars.AppContext, &appEventServer{EventServerIface: server})
 	}
-	serviceLB := slb.NewService(etcdServerUrls, appName)
-	serviceConfigClient := etcdconfig.NewServiceConfigClient(serviceLB)
-	var registerSequence = getServiceSequence(serviceIP, strconv.Itoa(int(port)))
-	err := serviceConfigClient.ClearConfig(registerSequence)
-	if err != nil && err != etcdconfig.ErrServiceConfigKeyNotExist {
+}
+
+// appEventServer passes every handler a context cancelled with the app context, the values of the event context are kept.
+type appEventServer struct {
+	event.EventServerIface
+}
+
+func (s *appEventServer) RegisterEventHandler(name string, handler func(ctx context.Context, body string) error) error {
+	return s.EventServerIface.RegisterEventHandler(name, func(eventCtx context.Context, body string) error {
+		if eventCtx == nil {
+			eventCtx = context.Background()
+		}
+		ctx, cancel := context.WithCancel(vars.AppContext)
+		defer cancel()
+		return handler(&taskContext{Context: ctx, values: eventCtx}, body)
+	})
+}
+
+// appUnRegisterService removes the instance of the application from the registry.
+func appUnRegisterService(appName string, port int64) error {
+	err := registry.Default().Deregister(context.Background(), registry.Instance{
+		Service: appName,
+		IP:      serviceIP,
+		Port:    strconv.Itoa(int(port)),
+	})
+	if err != nil {
 		if kelvins.ErrLogger != nil {
-			kelvins.ErrLogger.Errorf(context.TODO(), "etcd serviceConfigClient ClearConfig err: %v, key: %v",
-				err, serviceConfigClient.GetKeyName(appName, registerSequence))
+			kelvins.ErrLogger.Errorf(context.TODO(), "registry Deregister err: %v, service: %v", err, appName)
 		}
-		return fmt.Errorf("etcd clear service port exception")
+		return fmt.Errorf("registry deregister service port(%v) exception", port)
 	}
 
 	return nil
 }
 
-var serviceIP string
-
-func appRegisterServiceToEtcd(serviceKind, appName string, initialPort int64) (int64, error) {
-	var flagPort int64
-	if initialPort > 0 { // use self define port to start process
-		flagPort = initialPort
-	} else {
-		flagPort = int64(util.RandInt(50000, 60000))
-	}
-	currentPort := strconv.Itoa(int(flagPort))
-	etcdServerUrls := config.GetEtcdV3ServerURLs()
-	if etcdServerUrls == "" {
-		if kelvins.ErrLogger != nil {
-			kelvins.ErrLogger.Errorf(context.TODO(), "etcd not found environment variable(%v)", config.ENV_ETCDV3_SERVER_URLS)
-		}
-		return flagPort, fmt.Errorf("etcd not found environment variable(%v)", config.ENV_ETCDV3_SERVER_URLS)
+// appServicePort returns initialPort, or listens a port picked by the system if it is not set,
+// the listener is kept for the server and registered by the port it listens, name tells the listeners apart on restart.
+func appServicePort(kp *kprocess.KProcess, name string, initialPort int64) (int64, net.Listener, error) {
+	if initialPort > 0 {
+		return initialPort, nil, nil
 	}
-	var err error
-	serviceIP, err = getOutBoundIP()
+	network := appNetwork()
+	ln, err := kp.ListenFreePort(network, name)
 	if err != nil {
-		return 0, fmt.Errorf("lookup out bound ip err(%v)", err)
+		return 0, nil, fmt.Errorf("kprocess listen free port(%s) err: %v", network, err)
 	}
-
-	var registerSequence = getServiceSequence(serviceIP, currentPort)
-	serviceLB := slb.NewService(etcdServerUrls, appName)
-	serviceConfigClient := etcdconfig.NewServiceConfigClient(serviceLB)
-	serviceConfig, err := serviceConfigClient.GetConfig(registerSequence)
-	if err != nil && err != etcdconfig.ErrServiceConfigKeyNotExist {
-		if kelvins.ErrLogger != nil {
-			kelvins.ErrLogger.Errorf(context.TODO(), "etcd serviceConfig.GetConfig err: %v ,sequence(%v)", err, registerSequence)
-		}
-		return flagPort, fmt.Errorf("etcd register service sequence(%v) exception", registerSequence)
+	addr, ok := ln.Addr().(*net.TCPAddr)
+	if !ok {
+		ln.Close()
+		return 0, nil, fmt.Errorf("listen free port(%s) got addr %v", network, ln.Addr())
 	}
-	if serviceConfig != nil {
-		isExist := getServiceSequence(serviceConfig.ServiceIP, serviceConfig.ServicePort) == getServiceSequence(serviceIP, currentPort)
-		if isExist {
-			if kelvins.ErrLogger != nil {
-				kelvins.ErrLogger.Errorf(context.TODO(), "etcd serviceConfig.GetConfig sequence(%v) exist", registerSequence)
-			}
-			return flagPort, fmt.Errorf("etcd register service sequence(%v) exist", registerSequence)
-		}
+	return int64(addr.Port), ln, nil
+}
+
+// appNetwork returns the network the servers listen, default tcp.
+func appNetwork() string {
+	if kelvins.HttpServerSetting != nil && kelvins.HttpServerSetting.Network != "" {
+		return kelvins.HttpServerSetting.Network
 	}
+	return "tcp"
+}
 
-	err = serviceConfigClient.WriteConfig(registerSequence, etcdconfig.Config{
-		ServiceVersion: kelvins.Version,
-		ServicePort:    currentPort,
-		ServiceIP:      serviceIP,
-		ServiceKind:    serviceKind,
-		LastModified:   time.Now().Format(kelvins.ResponseTimeLayout),
-	})
+var serviceIP string
+
+// appRegisterService registers the instance of the application to the registry.
+func appRegisterService(serviceKind, protocol, appName string, port int64) error {
+	currentPort := strconv.Itoa(int(port))
+	var err error
+	serviceIP, err = getAdvertiseIP(kelvins.ServerSetting)
+	if err != nil {
+		return fmt.Errorf("lookup advertise ip err(%v)", err)
+	}
+
+	hostname, _ := os.Hostname()
+	ins := registry.Instance{
+		Service:          appName,
+		Kind:             serviceKind,
+		IP:               serviceIP,
+		Port:             currentPort,
+		Hostname:         hostname,
+		FrameworkVersion: kelvins.Version,
+		Weight:           setting.DefaultWeight,
+		Protocol:         protocol,
+		StartTime:        vars.AppStartTime.Format(kelvins.ResponseTimeLayout),
+		LastModified:     time.Now().Format(kelvins.ResponseTimeLayout),
+	}
+	if s := kelvins.ServerSetting; s != nil {
+		ins.Version = s.AppVersion
+		ins.BuildCommit = s.BuildCommit
+		ins.Zone = s.Zone
+		ins.Region = s.Region
+		ins.Weight = s.GetWeight()
+		ins.Tags = s.Tags
+		ins.Labels = s.GetLabels()
+	}
+	err = registry.Default().Register(context.Background(), ins)
 	if err != nil {
 		if kelvins.ErrLogger != nil {
-			kelvins.ErrLogger.Errorf(context.TODO(), "etcd writeConfig err: %v，sequence(%v) ", err, currentPort)
+			kelvins.ErrLogger.Errorf(context.TODO(), "registry Register err: %v，port(%v) ", err, currentPort)
 		}
-		err = fmt.Errorf("etcd register service port(%v) exception", currentPort)
+		err = fmt.Errorf("registry register service port(%v) exception: %v", currentPort, err)
 	}
 	vars.ServicePort = currentPort
 	vars.ServiceIp = serviceIP
-	return flagPort, err
+	return err
 }
 
-func getServiceSequence(ip, port string) (key string) {
-	ret := big.NewInt(0)
-	ret.SetBytes(net.ParseIP(ip).To4())
-	key = fmt.Sprintf("%v_%v", ret.Int64(), port)
-	return
+// getAdvertiseIP returns the address registered for the application: AdvertiseAddr, or the ip of AdvertiseInterface,
+// or the ip of the interface routing to the outside, ipv4 preferred.
+func getAdvertiseIP(s *setting.ServerSettingS) (string, error) {
+	if s != nil && s.AdvertiseAddr != "" {
+		return s.AdvertiseAddr, nil
+	}
+	if s != nil && s.AdvertiseInterface != "" {
+		iface, err := net.InterfaceByName(s.AdvertiseInterface)
+		if err != nil {
+			return "", fmt.Errorf("interface(%v) err: %v", s.AdvertiseInterface, err)
+		}
+		ip, err := interfaceIP(iface)
+		if err != nil {
+			return "", fmt.Errorf("interface(%v) err: %v", s.AdvertiseInterface, err)
+		}
+		return ip, nil
+	}
+	return getOutBoundIP()
 }
 
-func getOutBoundIP() (ip string, err error) {
-	conn, err := net.Dial("udp", "255.255.255.255:53")
+// getOutBoundIP returns the local ip of the route to a public address, no packet is sent by a udp dial.
+func getOutBoundIP() (string, error) {
+	for _, target := range []struct{ network, addr string }{
+		{"udp4", "8.8.8.8:53"},
+		{"udp6", "[2001:4860:4860::8888]:53"},
+	} {
+		conn, err := net.Dial(target.network, target.addr)
+		if err != nil {
+			continue
+		}
+		ip := conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+		if ip.IsGlobalUnicast() {
+			return ip.String(), nil
+		}
+	}
+	// no route to outside, eg: an isolated network
+	ifaces, err := net.Interfaces()
 	if err != nil {
-		return
+		return "", err
 	}
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	ip = strings.Split(localAddr.String(), ":")[0]
-	return
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if ip, err := interfaceIP(&iface); err == nil {
+			return ip, nil
+		}
+	}
+	return "", errors.New("no global unicast ip found")
+}
+
+// interfaceIP returns the global unicast ip of iface, ipv4 preferred.
+func interfaceIP(iface *net.Interface) (string, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	var ipv6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP.String(), nil
+		}
+		if ipv6 == nil {
+			ipv6 = ipNet.IP
+		}
+	}
+	if ipv6 == nil {
+		return "", errors.New("no global unicast ip")
+	}
+	return ipv6.String(), nil
 }
 
 var (
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"gitee.com/kelvins-io/common/event"
+	"gitee.com/kelvins-io/kelvins/config/setting"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
+)
+
+func TestGetAdvertiseIP(t *testing.T) {
+	cases := []struct {
+		name    string
+		setting *setting.ServerSettingS
+		expect  string
+		err     bool
+	}{
+		{"advertise addr", &setting.ServerSettingS{AdvertiseAddr: "10.0.0.1"}, "10.0.0.1", false},
+		{"advertise addr over interface", &setting.ServerSettingS{AdvertiseAddr: "fe80::1", AdvertiseInterface: "eth0"}, "fe80::1", false},
+		{"unknown interface", &setting.ServerSettingS{AdvertiseInterface: "kelvins-not-exist0"}, "", true},
+	}
+	for _, c := range cases {
+		got, err := getAdvertiseIP(c.setting)
+		if (err != nil) != c.err || got != c.expect {
+			t.Errorf("%s: getAdvertiseIP = %q, %v, expect %q, err %v", c.name, got, err, c.expect, c.err)
+		}
+	}
+}
+
+func TestInterfaceIP_Loopback(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("net.Interfaces err: %v", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		// the loopback addresses are not global unicast
+		if ip, err := interfaceIP(&iface); err == nil {
+			t.Errorf("interfaceIP(%s) = %v, expect err", iface.Name, ip)
+		}
+		return
+	}
+	t.Skip("no loopback interface")
+}
+
+type recordEventServer struct {
+	event.EventServerIface
+	handlers map[string]func(ctx context.Context, body string) error
+}
+
+func (s *recordEventServer) RegisterEventHandler(name string, handler func(ctx context.Context, body string) error) error {
+	s.handlers[name] = handler
+	return nil
+}
+
+func TestEventHandlerCtx(t *testing.T) {
+	type key struct{}
+	server := &recordEventServer{handlers: map[string]func(ctx context.Context, body string) error{}}
+	var registerCtx, handlerCtx context.Context
+	err := eventHandlerCtx(func(ctx context.Context, server event.EventServerIface) error {
+		registerCtx = ctx
+		return server.RegisterEventHandler("order-paid", func(ctx context.Context, body string) error {
+			handlerCtx = ctx
+			return nil
+		})
+	})(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registerCtx != vars.AppContext {
+		t.Error("register should be called with the app context")
+	}
+	if err := server.handlers["order-paid"](context.WithValue(context.Background(), key{}, "v"), "{}"); err != nil {
+		t.Fatal(err)
+	}
+	if handlerCtx == nil || handlerCtx.Value(key{}) != "v" {
+		t.Fatal("the handler context should keep the values of the event context")
+	}
+	if handlerCtx.Err() == nil {
+		t.Error("the handler context should be cancelled after the handler returns")
+	}
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
+	"gitee.com/kelvins-io/kelvins/util/kprocess"
+)
+
+// component is one application type run by runApplication.
+type component interface {
+	name() string
+	application() *kelvins.Application
+	// prepare sets up the component after initApplication, a port picked by the system is listened through kp
+	prepare(kp *kprocess.KProcess) error
+	// register registers the service after the dependencies are ready
+	register() error
+	// listen is called before the process is ready, the listeners are inherited on restart
+	listen(kp *kprocess.KProcess) error
+	// serve starts the component without blocking, done is called when a server exits by itself
+	serve(done func()) error
+	// drain stops taking new work, eg: health NOT_SERVING and unregister the service.
+	// drain and stop are called even if prepare was not called or failed
+	drain()
+	// stop waits for the in-flight work until ctx is done, then stops by force
+	stop(ctx context.Context) error
+}
+
+// RunComposite runs several application types in one process, eg: RunComposite(grpcApp, cronApp, queueApp).
+// the applications share the config, the global vars, the pid file and the process restart;
+// each type can be passed once, and they are stopped in the given order.
+// Name, LoggerRootPath, LoggerLevel and Environment are taken from the first application setting them.
+func RunComposite(applications ...interface{}) {
+	if len(applications) == 0 {
+		panic("compositeApp has no application")
+	}
+	// app instance once validate
+	{
+		err := appInstanceOnceValidate()
+		if err != nil {
+			logging.Fatal(err.Error())
+		}
+	}
+
+	var components []component
+	types := map[int32]bool{}
+	for _, application := range applications {
+		var (
+			c       component
+			appType int32
+		)
+		switch a := application.(type) {
+		case *kelvins.GRPCApplication:
+			if a == nil || a.Application == nil {
+				panic("grpcApplication is nil or application is nil")
+			}
+			appType = kelvins.AppTypeGrpc
+			kelvins.GRPCAppInstance = a
+			c = &grpcComponent{app: a}
+		case *kelvins.HTTPApplication:
+			if a == nil || a.Application == nil {
+				panic("httpApplication is nil or application is nil")
+			}
+			appType = kelvins.AppTypeHttp
+			kelvins.HttpAppInstance = a
+			c = &httpComponent{app: a}
+		case *kelvins.CronApplication:
+			if a == nil || a.Application == nil {
+				panic("cronApplication is nil or application is nil")
+			}
+			appType = kelvins.AppTypeCron
+			kelvins.CronAppInstance = a
+			c = &cronComponent{app: a}
+		case *kelvins.QueueApplication:
+			if a == nil || a.Application == nil {
+				panic("queueApplication is nil or application is nil")
+			}
+			appType = kelvins.AppTypeQueue
+			kelvins.QueueAppInstance = a
+			c = &queueComponent{app: a}
+		case *kelvins.WorkerApplication:
+			if a == nil || a.Application == nil {
+				panic("workerApplication is nil or application is nil")
+			}
+			appType = kelvins.AppTypeWorker
+			kelvins.WorkerAppInstance = a
+			c = &workerComponent{app: a}
+		default:
+			panic(fmt.Sprintf("compositeApp unsupported application type %T", application))
+		}
+		if types[appType] {
+			panic(fmt.Sprintf("compositeApp %s application is passed more than once", kelvins.AppTypeText[appType]))
+		}
+		types[appType] = true
+		c.application().Type = appType
+		components = append(components, c)
+	}
+
+	runApplication("compositeApp", compositeApplication(components), components...)
+}
+
+// compositeApplication merges the applications of components into the one initialized by initApplication.
+func compositeApplication(components []component) *kelvins.Application {
+	var apps []*kelvins.Application
+	for _, c := range components {
+		app := c.application()
+		exist := false
+		for _, a := range apps {
+			if a == app {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			apps = append(apps, app)
+		}
+	}
+
+	merged := &kelvins.Application{Type: apps[0].Type}
+	for _, app := range apps {
+		if merged.Name == "" {
+			merged.Name = app.Name
+		}
+		if merged.LoggerRootPath == "" {
+			merged.LoggerRootPath = app.LoggerRootPath
+		}
+		if merged.LoggerLevel == "" {
+			merged.LoggerLevel = app.LoggerLevel
+		}
+		if merged.Environment == "" {
+			merged.Environment = app.Environment
+		}
+	}
+	merged.LoadConfig = func() error {
+		for _, app := range apps {
+			if app.LoadConfig != nil {
+				if err := app.LoadConfig(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	merged.SetupVars = func() error {
+		for _, app := range apps {
+			app.Name = merged.Name
+			app.LoggerRootPath = merged.LoggerRootPath
+			app.LoggerLevel = merged.LoggerLevel
+			app.Environment = merged.Environment
+			if app.SetupVars != nil {
+				if err := app.SetupVars(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	merged.StopFunc = func() error {
+		var errs []error
+		for _, app := range apps {
+			if app.StopFunc != nil {
+				if err := app.StopFunc(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		return combineErrors(errs)
+	}
+	return merged
+}
+
+// runApplication runs components until the process exits, then shuts them down.
+// the admin server is appended as the last component so it keeps serving until the others are stopped.
+func runApplication(name string, application *kelvins.Application, components ...component) {
+	components = append(components, &adminComponent{app: application})
+	err := runComponents(application, components)
+	if err != nil {
+		logging.Infof("%s run err: %v\n", name, err)
+	}
+
+	ctx, cancel := appPrepareForceExit()
+	defer cancel()
+	err = appShutdown(ctx, application, components...)
+	if err != nil {
+		logging.Infof("%s appShutdown err: %v\n", name, err)
+	}
+}
+
+func runComponents(application *kelvins.Application, components []component) error {
+	// 1. init application
+	err := initApplication(application)
+	if err != nil {
+		return err
+	}
+	if !appProcessNext {
+		return nil
+	}
+
+	// 2. prepare components, the process is opened first so the ports picked by the system are inherited on restart
+	kp := new(kprocess.KProcess)
+	err = kp.Open(kelvins.PIDFile)
+	if err != nil {
+		return fmt.Errorf("kprocess open pidFile(%v) err: %v", kelvins.PIDFile, err)
+	}
+	for _, c := range components {
+		err = c.prepare(kp)
+		if err != nil {
+			return fmt.Errorf("%s prepare err: %v", c.name(), err)
+		}
+	}
+	// the queue servers and the resources registered by SetupVars
+	err = initResources()
+	if err != nil {
+		return err
+	}
+	plugins, err := initPlugins()
+	if err != nil {
+		return err
+	}
+	deps := resourceChecks()
+	err = waitDependencies(deps)
+	if err != nil {
+		return err
+	}
+	registerDependencyChecks(deps)
+	err = startPlugins(plugins)
+	if err != nil {
+		return err
+	}
+	for _, c := range components {
+		err = c.register()
+		if err != nil {
+			return fmt.Errorf("%s register err: %v", c.name(), err)
+		}
+	}
+	err = runHooks(vars.AppContext, kelvins.HookBeforeServe)
+	if err != nil {
+		return err
+	}
+
+	// 3. listen, the pid file is written once every component listened
+	for _, c := range components {
+		err = c.listen(kp)
+		if err != nil {
+			return err
+		}
+	}
+	err = kp.Ready()
+	if err != nil {
+		return fmt.Errorf("kprocess ready pidFile(%v) err: %v", kelvins.PIDFile, err)
+	}
+
+	// 4. serve until a server exits or the process is stopped
+	serverClose := make(chan struct{})
+	var serverCloseOnce sync.Once
+	done := func() {
+		serverCloseOnce.Do(func() {
+			close(serverClose)
+		})
+	}
+	for _, c := range components {
+		err = c.serve(done)
+		if err != nil {
+			return fmt.Errorf("%s serve err: %v", c.name(), err)
+		}
+	}
+	err = runHooks(vars.AppContext, kelvins.HookAfterServe)
+	if err != nil {
+		return err
+	}
+	go watchHealth()
+
+	select {
+	case <-serverClose:
+	case <-kp.Exit():
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"gitee.com/kelvins-io/kelvins"
 	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
 	"gitee.com/kelvins-io/kelvins/util/kprocess"
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
@@ -30,53 +31,48 @@ func RunCronApplication(application *kelvins.CronApplication) {
 	application.Type = kelvins.AppTypeCron
 	kelvins.CronAppInstance = application
 
-	err := runCron(application)
-	if err != nil {
-		logging.Infof("cronApp runCron err: %v\n", err)
-	}
+	runApplication("cronApp", application.Application, &cronComponent{app: application})
+}
 
-	appPrepareForceExit()
-	if application.Cron != nil {
-		application.Cron.Stop()
-		logging.Info("cronApp Task Stop over")
-	}
-	err = appShutdown(application.Application)
-	if err != nil {
-		logging.Infof("cronApp appShutdown err: %v\n", err)
-	}
+// cronComponent runs cron application.
+type cronComponent struct {
+	app     *kelvins.CronApplication
+	stopped context.Context
 }
 
-// runCron prepares cron application.
-func runCron(cronApp *kelvins.CronApplication) error {
-	var err error
+func (c *cronComponent) name() string {
+	return "cronApp"
+}
 
-	// 1. init application
-	err = initApplication(cronApp.Application)
-	if err != nil {
-		return err
-	}
-	if !appProcessNext {
-		return err
-	}
+func (c *cronComponent) application() *kelvins.Application {
+	return c.app.Application
+}
 
-	// 2 init cron vars
+// prepare registers the cron jobs.
+func (c *cronComponent) prepare(kp *kprocess.KProcess) error {
+	cronApp := c.app
+	var err error
+
+	// 1. init cron vars
 	err = setupCronVars(cronApp)
 	if err != nil {
 		return err
 	}
 
-	// 3  register event handler
+	// 2. register event handler
 	if kelvins.EventServerAliRocketMQ != nil {
 		logging.Info("cronApp Start event server")
 		if cronApp.RegisterEventProducer != nil {
-			appRegisterEventProducer(cronApp.RegisterEventProducer, cronApp.Type)
+			appRegisterEventProducer(cronApp.RegisterEventProducer, kelvins.AppTypeCron)
 		}
 		if cronApp.RegisterEventHandler != nil {
-			appRegisterEventHandler(cronApp.RegisterEventHandler, cronApp.Type)
+			appRegisterEventHandler(cronApp.RegisterEventHandler, kelvins.AppTypeCron)
+		} else if cronApp.RegisterEventHandlerCtx != nil {
+			appRegisterEventHandler(eventHandlerCtx(cronApp.RegisterEventHandlerCtx), kelvins.AppTypeCron)
 		}
 	}
 
-	// 4. register cron jobs
+	// 3. register cron jobs
 	if cronApp.GenCronJobs != nil {
 		cronJobs := cronApp.GenCronJobs()
 		if len(cronJobs) != 0 {
@@ -88,7 +84,7 @@ func runCron(cronApp *kelvins.CronApplication) error {
 				if j.Spec == "" {
 					return fmt.Errorf("lack of CronJob.Spec")
 				}
-				if j.Job == nil {
+				if j.Job == nil && j.JobCtx == nil {
 					return fmt.Errorf("lack of CronJob.Job")
 				}
 				if _, ok := jobNameDict[j.Name]; ok {
@@ -109,7 +105,12 @@ func runCron(cronApp *kelvins.CronApplication) error {
 					}
 				}
 				job.logger = logger
-				_, err = cronApp.Cron.AddFunc(j.Spec, job.warpJob(j.Job))
+				jobCtx := j.JobCtx
+				if j.Job != nil {
+					jobFunc := j.Job
+					jobCtx = func(ctx context.Context) { jobFunc() }
+				}
+				_, err = cronApp.Cron.AddFunc(j.Spec, job.warpJob(jobCtx))
 				if err != nil {
 					return fmt.Errorf("addFunc err: %v", err)
 				}
@@ -117,20 +118,44 @@ func runCron(cronApp *kelvins.CronApplication) error {
 		}
 	}
 
-	// 5. run cron app
-	kp := new(kprocess.KProcess)
-	_, err = kp.Listen("", "", kelvins.PIDFile)
-	if err != nil {
-		return fmt.Errorf("kprocess listen pidFile(%v) err: %v", kelvins.PIDFile, err)
-	}
-	logging.Info("cronApp Start cron task")
-	cronApp.Cron.Start()
+	return nil
+}
 
-	<-kp.Exit()
+func (c *cronComponent) register() error {
+	return nil
+}
+
+func (c *cronComponent) listen(kp *kprocess.KProcess) error {
+	return nil
+}
 
+func (c *cronComponent) serve(done func()) error {
+	logging.Info("cronApp Start cron task")
+	c.app.Cron.Start()
 	return nil
 }
 
+// drain stops scheduling the cron jobs.
+func (c *cronComponent) drain() {
+	if c.app.Cron != nil {
+		c.stopped = c.app.Cron.Stop()
+	}
+}
+
+// stop waits for the running cron jobs.
+func (c *cronComponent) stop(ctx context.Context) error {
+	if c.stopped == nil {
+		return nil
+	}
+	select {
+	case <-c.stopped.Done():
+		logging.Info("cronApp Task Stop over")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d cron job still running: %v", inflight.count(inflightCron), ctx.Err())
+	}
+}
+
 // setupCronVars ...
 func setupCronVars(cronApp *kelvins.CronApplication) error {
 	cronApp.Cron = cron.New(cron.WithSeconds())
@@ -151,8 +176,8 @@ type cronJob struct {
 
 var cronJobCtx = context.Background()
 
-// warpJob warps job with log and panic recover.
-func (c *cronJob) warpJob(job func()) func() {
+// warpJob warps job with log and panic recover, job runs with a context derived from the app context.
+func (c *cronJob) warpJob(job func(ctx context.Context)) func() {
 	return func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -163,13 +188,16 @@ func (c *cronJob) warpJob(job func()) func() {
 				}
 			}
 		}()
+		defer inflight.begin(inflightCron, c.name)()
 		UUID := uuid.New()
 		startTime := time.Now()
 		if c.logger != nil {
 			c.logger.Infof(cronJobCtx, "Name: %s Uuid: %s StartTime: %s",
 				c.name, UUID, startTime.Format("2006-01-02 15:04:05.000"))
 		}
-		job()
+		ctx, cancel := context.WithCancel(vars.AppContext)
+		defer cancel()
+		job(ctx)
 		endTime := time.Now()
 		duration := endTime.Sub(startTime)
 		if c.logger != nil {
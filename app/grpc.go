@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"net"
 	"time"
 
 	"gitee.com/kelvins-io/kelvins"
@@ -15,6 +16,7 @@ import (
 	"gitee.com/kelvins-io/kelvins/util/grpc_interceptor"
 	"gitee.com/kelvins-io/kelvins/util/kprocess"
 	"gitee.com/kelvins-io/kelvins/util/middleware"
+	"gitee.com/kelvins-io/kelvins/util/registry"
 	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
@@ -38,64 +40,41 @@ func RunGRPCApplication(application *kelvins.GRPCApplication) {
 	application.Type = kelvins.AppTypeGrpc
 	kelvins.GRPCAppInstance = application
 
-	err := runGRPC(application)
-	if err != nil {
-		logging.Infof("grpcApp runGRPC err: %v\n", err)
-	}
+	runApplication("grpcApp", application.Application, &grpcComponent{app: application})
+}
 
-	appPrepareForceExit()
-	// Wait for connections to drain.
-	if application.HttpServer != nil {
-		err = application.HttpServer.Shutdown(context.Background())
-		if err != nil {
-			logging.Infof("grpcApp HttpServer.Shutdown err: %v\n", err)
-		}
-	}
-	if application.GRPCServer != nil {
-		err = stopGRPC(application)
-		if err != nil {
-			logging.Infof("grpcApp stopGRPC err: %v\n", err)
-		}
-	}
-	err = appShutdown(application.Application)
-	if err != nil {
-		logging.Infof("grpcApp appShutdown err: %v\n", err)
-	}
+// grpcComponent runs grpc application.
+type grpcComponent struct {
+	app        *kelvins.GRPCApplication
+	registered bool
+	ln         net.Listener
 }
 
-// runGRPC runs grpc application.
-func runGRPC(grpcApp *kelvins.GRPCApplication) error {
-	var err error
+func (c *grpcComponent) name() string {
+	return "grpcApp"
+}
 
-	// 1. init application
-	err = initApplication(grpcApp.Application)
-	if err != nil {
-		return err
-	}
-	if !appProcessNext {
-		return err
-	}
+func (c *grpcComponent) application() *kelvins.Application {
+	return c.app.Application
+}
 
-	// 2 init grpc vars
+func (c *grpcComponent) prepare(kp *kprocess.KProcess) error {
+	grpcApp := c.app
+	var err error
+
+	// 1. init grpc vars
 	err = setupGRPCVars(grpcApp)
 	if err != nil {
 		return err
 	}
 
-	// 3. register service port
-	portEtcd, err := appRegisterServiceToEtcd(kelvins.AppTypeText[grpcApp.Type], grpcApp.Name, grpcApp.Port)
+	// 2. set service port, it is registered after the dependencies are ready
+	grpcApp.Port, c.ln, err = appServicePort(kp, "grpc", grpcApp.Port)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err := appUnRegisterServiceToEtcd(grpcApp.Name, grpcApp.Port)
-		if err != nil {
-			return
-		}
-	}()
-	grpcApp.Port = portEtcd
 
-	// 4. register grpc and http
+	// 3. register grpc and http
 	if grpcApp.RegisterGRPCServer != nil {
 		err = grpcApp.RegisterGRPCServer(grpcApp.GRPCServer)
 		if err != nil {
@@ -121,46 +100,93 @@ func runGRPC(grpcApp *kelvins.GRPCApplication) error {
 			return fmt.Errorf("registerHttpRoute err: %v", err)
 		}
 	}
+	// the health handlers are served on the gateway port too, the gateway "/" catch-all does not hide them
+	setupInternal.RegisterHealthHandlers(grpcApp.Mux, kelvins.ReadinessSetting.GetLivenessPath(), kelvins.ReadinessSetting.GetReadinessPath())
 
-	// 5. register event producer
+	// 4. register event producer
 	if kelvins.EventServerAliRocketMQ != nil {
 		logging.Info("grpcApp Start event server")
 		if grpcApp.RegisterEventProducer != nil {
-			appRegisterEventProducer(grpcApp.RegisterEventProducer, grpcApp.Type)
+			appRegisterEventProducer(grpcApp.RegisterEventProducer, kelvins.AppTypeGrpc)
 		}
 		if grpcApp.RegisterEventHandler != nil {
-			appRegisterEventHandler(grpcApp.RegisterEventHandler, grpcApp.Type)
+			appRegisterEventHandler(grpcApp.RegisterEventHandler, kelvins.AppTypeGrpc)
+		} else if grpcApp.RegisterEventHandlerCtx != nil {
+			appRegisterEventHandler(eventHandlerCtx(grpcApp.RegisterEventHandlerCtx), kelvins.AppTypeGrpc)
 		}
 	}
 
-	// 6. start server
-	network := "tcp"
-	if kelvins.HttpServerSetting.Network != "" {
-		network = kelvins.HttpServerSetting.Network
+	return nil
+}
+
+func (c *grpcComponent) register() error {
+	err := appRegisterService(kelvins.AppTypeText[kelvins.AppTypeGrpc], registry.ProtocolGRPC, c.app.Name, c.app.Port)
+	if err != nil {
+		return err
 	}
-	kp := new(kprocess.KProcess)
-	ln, err := kp.Listen(network, fmt.Sprintf(":%d", grpcApp.Port), kelvins.PIDFile)
+	c.registered = true
+	return nil
+}
+
+func (c *grpcComponent) listen(kp *kprocess.KProcess) (err error) {
+	network := appNetwork()
+	if c.ln != nil {
+		// listened by prepare on the port picked by the system
+		logging.Infof("grpcApp server listen(%s:%d) \n", network, c.app.Port)
+		return nil
+	}
+	c.ln, err = kp.ListenAddr(network, fmt.Sprintf(":%d", c.app.Port))
 	if err != nil {
-		return fmt.Errorf("kprocess listen(%s:%d) pidFile(%v) err: %v", network, grpcApp.Port, kelvins.PIDFile, err)
+		return fmt.Errorf("kprocess listen(%s:%d) pidFile(%v) err: %v", network, c.app.Port, kelvins.PIDFile, err)
 	}
-	logging.Infof("grpcApp server listen(%s:%d) \n", network, grpcApp.Port)
-	serverClose := make(chan struct{})
+	logging.Infof("grpcApp server listen(%s:%d) \n", network, c.app.Port)
+	return nil
+}
+
+func (c *grpcComponent) serve(done func()) error {
 	go func() {
-		defer func() {
-			close(serverClose)
-		}()
-		err := grpcApp.HttpServer.Serve(ln)
+		defer done()
+		err := c.app.HttpServer.Serve(c.ln)
 		if err != nil {
 			logging.Infof("grpcApp HttpServer serve err: %v", err)
 		}
 	}()
+	return nil
+}
 
-	select {
-	case <-serverClose:
-	case <-kp.Exit():
+func (c *grpcComponent) drain() {
+	grpcApp := c.app
+	if grpcApp.HealthServer != nil {
+		grpcApp.HealthServer.Shutdown()
 	}
+	if c.registered {
+		err := appUnRegisterService(grpcApp.Name, grpcApp.Port)
+		if err != nil {
+			logging.Infof("grpcApp appUnRegisterService err: %v\n", err)
+		}
+	}
+	if grpcApp.HttpServer != nil {
+		grpcApp.HttpServer.SetKeepAlivesEnabled(false)
+	}
+}
 
-	return err
+func (c *grpcComponent) stop(ctx context.Context) error {
+	grpcApp := c.app
+	// Wait for connections to drain.
+	if grpcApp.HttpServer != nil {
+		err := grpcApp.HttpServer.Shutdown(ctx)
+		if err != nil {
+			logging.Infof("grpcApp HttpServer.Shutdown err: %v\n", err)
+			grpcApp.HttpServer.Close()
+		}
+	}
+	if grpcApp.GRPCServer != nil {
+		err := stopGRPC(ctx, grpcApp)
+		if err != nil {
+			return fmt.Errorf("stopGRPC err: %v", err)
+		}
+	}
+	return nil
 }
 
 const (
@@ -189,11 +215,16 @@ func setupGRPCVars(grpcApp *kelvins.GRPCApplication) error {
 		rateLimitParam           = kelvins.RPCRateLimitSetting
 		rateLimitInterceptor     = middleware.NewRPCRateLimitInterceptor(rateLimitParam.MaxConcurrent)
 	)
+	serverUnaryInterceptors = append(serverUnaryInterceptors, inflightUnaryServerInterceptor)
 	serverUnaryInterceptors = append(serverUnaryInterceptors, appInterceptor.Metadata)
 	serverUnaryInterceptors = append(serverUnaryInterceptors, appInterceptor.Recovery)
-	if rateLimitParam.MaxConcurrent > 0 {
-		serverUnaryInterceptors = append(serverUnaryInterceptors, rateLimitInterceptor.UnaryServerInterceptor())
-	}
+	// always installed so MaxConcurrent can be changed by config reload, 0 means no limit
+	serverUnaryInterceptors = append(serverUnaryInterceptors, rateLimitInterceptor.UnaryServerInterceptor())
+	config.OnChange(config.SectionRPCRateLimit, func(section string, value interface{}) {
+		if s, ok := value.(*setting.RPCRateLimitSettingS); ok && s != nil {
+			rateLimitInterceptor.SetMaxConcurrent(s.MaxConcurrent)
+		}
+	})
 	serverUnaryInterceptors = append(serverUnaryInterceptors, appInterceptor.Logger)
 	if kelvins.RPCAuthSetting == nil {
 		kelvins.RPCAuthSetting = new(setting.RPCAuthSettingS)
@@ -202,11 +233,10 @@ func setupGRPCVars(grpcApp *kelvins.GRPCApplication) error {
 	if len(grpcApp.UnaryServerInterceptors) > 0 {
 		serverUnaryInterceptors = append(serverUnaryInterceptors, grpcApp.UnaryServerInterceptors...)
 	}
+	serverStreamInterceptors = append(serverStreamInterceptors, inflightStreamServerInterceptor)
 	serverStreamInterceptors = append(serverStreamInterceptors, appInterceptor.StreamMetadata)
 	serverStreamInterceptors = append(serverStreamInterceptors, appInterceptor.RecoveryStream)
-	if rateLimitParam.MaxConcurrent > 0 {
-		serverStreamInterceptors = append(serverStreamInterceptors, rateLimitInterceptor.StreamServerInterceptor())
-	}
+	serverStreamInterceptors = append(serverStreamInterceptors, rateLimitInterceptor.StreamServerInterceptor())
 	serverStreamInterceptors = append(serverStreamInterceptors, appInterceptor.StreamLogger)
 	serverStreamInterceptors = append(serverStreamInterceptors, authInterceptor.StreamServerInterceptor(kelvins.RPCAuthSetting))
 	if len(grpcApp.StreamServerInterceptors) > 0 {
@@ -356,11 +386,21 @@ func setupGRPCVars(grpcApp *kelvins.GRPCApplication) error {
 	return nil
 }
 
-func stopGRPC(grpcApp *kelvins.GRPCApplication) error {
+// stopGRPC stops the server gracefully, the running rpc are canceled when ctx is done.
+func stopGRPC(ctx context.Context, grpcApp *kelvins.GRPCApplication) error {
 	if grpcApp.HealthServer != nil {
 		grpcApp.HealthServer.Shutdown()
 	}
-	grpcApp.GRPCServer.GracefulStop()
-
-	return nil
+	stopped := make(chan struct{})
+	go func() {
+		grpcApp.GRPCServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		grpcApp.GRPCServer.Stop()
+		return fmt.Errorf("graceful stop %v, force stop", ctx.Err())
+	}
 }
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+)
+
+// runHooks runs the hooks of phase in order within ctx. the startup phases stop at the first failed hook,
+// the shutdown phases run every hook and return the combined error.
+func runHooks(ctx context.Context, phase kelvins.HookPhase) error {
+	all := phase == kelvins.HookBeforeShutdown || phase == kelvins.HookAfterShutdown
+	var errs []error
+	for _, hook := range kelvins.Hooks(phase) {
+		err := runHook(ctx, hook)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("hook %s(%s) err: %v", kelvins.HookPhaseText[phase], hook.Name, err)
+		if !all {
+			return err
+		}
+		logging.Infof("%v\n", err)
+		errs = append(errs, err)
+	}
+	return combineErrors(errs)
+}
+
+func runHook(parent context.Context, hook kelvins.Hook) (err error) {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = kelvins.DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- hook.Func(ctx)
+	}()
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("timeout after %v: %v", timeout, ctx.Err())
+	}
+	return err
+}
+
+// errorList is the combined error of the steps which all run even if one fails.
+type errorList []error
+
+func (e errorList) Error() string {
+	msg := make([]string, 0, len(e))
+	for _, err := range e {
+		msg = append(msg, err.Error())
+	}
+	return strings.Join(msg, "; ")
+}
+
+func combineErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errorList(errs)
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	"gitee.com/kelvins-io/kelvins/config/setting"
 	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/config/setting"
 	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
 	setupInternal "gitee.com/kelvins-io/kelvins/internal/setup"
 	"gitee.com/kelvins-io/kelvins/util/gin_helper"
+	"gitee.com/kelvins-io/kelvins/util/health"
 	"gitee.com/kelvins-io/kelvins/util/kprocess"
+	"gitee.com/kelvins-io/kelvins/util/middleware"
+	"gitee.com/kelvins-io/kelvins/util/registry"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -35,57 +40,41 @@ func RunHTTPApplication(application *kelvins.HTTPApplication) {
 	application.Type = kelvins.AppTypeHttp
 	kelvins.HttpAppInstance = application
 
-	err := runHTTP(application)
-	if err != nil {
-		logging.Infof("HttpApp runHTTP err: %v\n", err)
-	}
+	runApplication("httpApp", application.Application, &httpComponent{app: application})
+}
 
-	appPrepareForceExit()
-	// Wait for connections to drain.
-	if application.HttpServer != nil {
-		err = application.HttpServer.Shutdown(context.Background())
-		if err != nil {
-			logging.Infof("HttpApp HttpServer Shutdown err: %v\n", err)
-		}
-	}
-	err = appShutdown(application.Application)
-	if err != nil {
-		logging.Infof("HttpApp appShutdown err: %v\n", err)
-	}
+// httpComponent runs http application.
+type httpComponent struct {
+	app        *kelvins.HTTPApplication
+	registered bool
+	ln         net.Listener
 }
 
-func runHTTP(httpApp *kelvins.HTTPApplication) error {
-	var err error
+func (c *httpComponent) name() string {
+	return "httpApp"
+}
 
-	// 1. init application
-	err = initApplication(httpApp.Application)
-	if err != nil {
-		return err
-	}
-	if !appProcessNext {
-		return err
-	}
+func (c *httpComponent) application() *kelvins.Application {
+	return c.app.Application
+}
+
+func (c *httpComponent) prepare(kp *kprocess.KProcess) error {
+	httpApp := c.app
+	var err error
 
-	// 2 init http vars
+	// 1. init http vars
 	err = setupHTTPVars(httpApp)
 	if err != nil {
 		return err
 	}
 
-	// 3. set init service port
-	portEtcd, err := appRegisterServiceToEtcd(kelvins.AppTypeText[httpApp.Type], httpApp.Name, httpApp.Port)
+	// 2. set init service port, it is registered after the dependencies are ready
+	httpApp.Port, c.ln, err = appServicePort(kp, "http", httpApp.Port)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err := appUnRegisterServiceToEtcd(httpApp.Name, httpApp.Port)
-		if err != nil {
-			return
-		}
-	}()
-	httpApp.Port = portEtcd
 
-	// 4. register http
+	// 3. register http
 	var handler http.Handler
 	debug := false
 	if kelvins.ServerSetting != nil {
@@ -104,9 +93,17 @@ func runHTTP(httpApp *kelvins.HTTPApplication) error {
 		handler = httpGinEng
 		httpGinEng.Use(gin_helper.Metadata(debug))
 		httpGinEng.Use(gin_helper.Cors())
-		if kelvins.HttpRateLimitSetting != nil && kelvins.HttpRateLimitSetting.MaxConcurrent > 0 {
-			httpGinEng.Use(gin_helper.RateLimit(kelvins.HttpRateLimitSetting.MaxConcurrent))
+		// always installed so MaxConcurrent can be changed by config reload, 0 means no limit
+		rateLimiter := middleware.NewKelvinsRateLimit(0)
+		if kelvins.HttpRateLimitSetting != nil {
+			rateLimiter.SetMaxConcurrent(kelvins.HttpRateLimitSetting.MaxConcurrent)
 		}
+		config.OnChange(config.SectionHttpRateLimit, func(section string, value interface{}) {
+			if s, ok := value.(*setting.HttpRateLimitSettingS); ok && s != nil {
+				rateLimiter.SetMaxConcurrent(s.MaxConcurrent)
+			}
+		})
+		httpGinEng.Use(gin_helper.RateLimiter(rateLimiter))
 		if debug {
 			pprof.Register(httpGinEng, "/debug")
 			httpGinEng.GET("/debug/metrics", ginMetricsApi)
@@ -114,6 +111,8 @@ func runHTTP(httpApp *kelvins.HTTPApplication) error {
 		httpGinEng.GET("/", ginIndexApi)
 		httpGinEng.GET("/ping", ginPingApi)
 		httpApp.RegisterHttpGinRoute(httpGinEng)
+		ginHealthRoute(httpGinEng, kelvins.ReadinessSetting.GetLivenessPath(), health.LivenessHandler)
+		ginHealthRoute(httpGinEng, kelvins.ReadinessSetting.GetReadinessPath(), health.ReadinessHandler)
 	} else {
 		httpApp.Mux = setupInternal.NewServerMux(debug)
 		handler = httpApp.Mux
@@ -125,13 +124,15 @@ func runHTTP(httpApp *kelvins.HTTPApplication) error {
 				return fmt.Errorf("registerHttpRoute err: %v", err)
 			}
 		}
+		setupInternal.RegisterHealthHandlers(httpApp.Mux, kelvins.ReadinessSetting.GetLivenessPath(), kelvins.ReadinessSetting.GetReadinessPath())
 		logging.Info("httpApp http handler selected [http.ServeMux]")
 	}
 	if handler == nil {
 		return fmt.Errorf("no http handler??? ")
 	}
+	handler = inflightHandler(handler)
 
-	// 5. set http server
+	// 4. set http server
 	var httpSer *http.Server
 	if kelvins.HttpServerSetting == nil {
 		kelvins.HttpServerSetting = new(setting.HttpServerSettingS)
@@ -145,44 +146,80 @@ func runHTTP(httpApp *kelvins.HTTPApplication) error {
 	}
 	httpApp.HttpServer = httpSer
 
-	// 6. register event producer
+	// 5. register event producer
 	if kelvins.EventServerAliRocketMQ != nil {
 		logging.Info("httpApp start event server")
 		if httpApp.RegisterEventProducer != nil {
-			appRegisterEventProducer(httpApp.RegisterEventProducer, httpApp.Type)
+			appRegisterEventProducer(httpApp.RegisterEventProducer, kelvins.AppTypeHttp)
 		}
 		if httpApp.RegisterEventHandler != nil {
-			appRegisterEventHandler(httpApp.RegisterEventHandler, httpApp.Type)
+			appRegisterEventHandler(httpApp.RegisterEventHandler, kelvins.AppTypeHttp)
+		} else if httpApp.RegisterEventHandlerCtx != nil {
+			appRegisterEventHandler(eventHandlerCtx(httpApp.RegisterEventHandlerCtx), kelvins.AppTypeHttp)
 		}
 	}
 
-	// 7. start server
-	network := "tcp"
-	if kelvins.HttpServerSetting.Network != "" {
-		network = kelvins.HttpServerSetting.Network
+	return nil
+}
+
+func (c *httpComponent) register() error {
+	err := appRegisterService(kelvins.AppTypeText[kelvins.AppTypeHttp], httpProtocol(c.app), c.app.Name, c.app.Port)
+	if err != nil {
+		return err
+	}
+	c.registered = true
+	return nil
+}
+
+func (c *httpComponent) listen(kp *kprocess.KProcess) (err error) {
+	network := appNetwork()
+	if c.ln != nil {
+		// listened by prepare on the port picked by the system
+		logging.Infof("httpApp server listen(%s:%d) \n", network, c.app.Port)
+		return nil
 	}
-	kp := new(kprocess.KProcess)
-	ln, err := kp.Listen(network, fmt.Sprintf(":%d", httpApp.Port), kelvins.PIDFile)
+	c.ln, err = kp.ListenAddr(network, fmt.Sprintf(":%d", c.app.Port))
 	if err != nil {
-		return fmt.Errorf("kprocess listen(%s:%d) pidFile(%v) err: %v", network, httpApp.Port, kelvins.PIDFile, err)
+		return fmt.Errorf("kprocess listen(%s:%d) pidFile(%v) err: %v", network, c.app.Port, kelvins.PIDFile, err)
 	}
-	logging.Infof("httpApp server listen(%s:%d) \n", network, httpApp.Port)
-	serverClose := make(chan struct{})
+	logging.Infof("httpApp server listen(%s:%d) \n", network, c.app.Port)
+	return nil
+}
+
+func (c *httpComponent) serve(done func()) error {
 	go func() {
-		defer func() {
-			close(serverClose)
-		}()
-		err = httpApp.HttpServer.Serve(ln)
+		defer done()
+		err := c.app.HttpServer.Serve(c.ln)
 		if err != nil {
 			logging.Infof("httpApp HttpServer serve err: %v", err)
 		}
 	}()
+	return nil
+}
 
-	select {
-	case <-serverClose:
-	case <-kp.Exit():
+func (c *httpComponent) drain() {
+	httpApp := c.app
+	if c.registered {
+		err := appUnRegisterService(httpApp.Name, httpApp.Port)
+		if err != nil {
+			logging.Infof("httpApp appUnRegisterService err: %v\n", err)
+		}
 	}
+	if httpApp.HttpServer != nil {
+		httpApp.HttpServer.SetKeepAlivesEnabled(false)
+	}
+}
 
+func (c *httpComponent) stop(ctx context.Context) error {
+	httpApp := c.app
+	// Wait for connections to drain.
+	if httpApp.HttpServer != nil {
+		err := httpApp.HttpServer.Shutdown(ctx)
+		if err != nil {
+			httpApp.HttpServer.Close()
+			return fmt.Errorf("HttpServer Shutdown err: %v, force close", err)
+		}
+	}
 	return nil
 }
 
@@ -264,3 +301,46 @@ func ginIndexApi(c *gin.Context) {
 func ginPingApi(c *gin.Context) {
 	gin_helper.JsonResponse(c, http.StatusOK, gin_helper.SUCCESS, time.Now().Format(kelvins.ResponseTimeLayout))
 }
+
+// httpProtocol returns the protocol registered for the http app.
+func httpProtocol(httpApp *kelvins.HTTPApplication) string {
+	if kelvins.HttpServerSetting == nil || !kelvins.HttpServerSetting.SupportH2 {
+		return registry.ProtocolHTTP
+	}
+	if httpApp.TlsConfig != nil {
+		return registry.ProtocolH2
+	}
+	return registry.ProtocolH2C
+}
+
+// ginHealthRoute registers a health handler after the app routes, a path used by the app or - is skipped,
+// a path conflicting with a wildcard route of the app eg: /:name is skipped too.
+func ginHealthRoute(eng *gin.Engine, path string, handler http.HandlerFunc) {
+	if path == "" || path == "-" {
+		return
+	}
+	for _, route := range eng.Routes() {
+		if route.Method != http.MethodGet {
+			continue
+		}
+		if route.Path == path {
+			logging.Infof("httpApp health handler %s is skipped, the path is used by the app\n", path)
+			return
+		}
+		if ginWildcardConflict(route.Path, path) {
+			logging.Infof("httpApp health handler %s is skipped, conflict with the app route %s\n", path, route.Path)
+			return
+		}
+	}
+	eng.GET(path, gin.WrapF(handler))
+}
+
+// ginWildcardConflict reports whether the static path can not be added next to routePath,
+// gin rejects a static path sharing the prefix before a :param or *catchall segment of routePath.
+func ginWildcardConflict(routePath, path string) bool {
+	i := strings.IndexAny(routePath, ":*")
+	if i < 0 {
+		return false
+	}
+	return strings.HasPrefix(path, routePath[:i]) && len(path) > i
+}
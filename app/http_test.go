@@ -0,0 +1,49 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGinHealthRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }
+	cases := []struct {
+		name       string
+		appRoute   string
+		path       string
+		registered bool
+	}{
+		{"unused path", "/api/v1/users", "/healthz", true},
+		{"used path", "/healthz", "/healthz", false},
+		{"param at the same segment", "/:name", "/healthz", false},
+		{"catchall at the same segment", "/*any", "/healthz", false},
+		{"param under another prefix", "/api/:id", "/healthz", true},
+		{"param under the same prefix", "/health/:check", "/health/ready", false},
+		{"disabled", "/api", "-", false},
+	}
+	for _, c := range cases {
+		eng := gin.New()
+		eng.GET(c.appRoute, func(ctx *gin.Context) {})
+		ginHealthRoute(eng, c.path, ok)
+		registered := false
+		for _, route := range eng.Routes() {
+			if route.Path == c.path && route.Path != c.appRoute {
+				registered = true
+			}
+		}
+		if registered != c.registered {
+			t.Errorf("%s: registered = %v, expect %v", c.name, registered, c.registered)
+		}
+		if registered {
+			w := httptest.NewRecorder()
+			eng.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != http.StatusTeapot {
+				t.Errorf("%s: GET %s = %d, expect the health handler", c.name, c.path, w.Code)
+			}
+		}
+	}
+}
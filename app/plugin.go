@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/config"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
+	"gitee.com/kelvins-io/kelvins/util/health"
+)
+
+// pluginsStarted holds the started plugins in start order.
+var pluginsStarted []kelvins.Plugin
+
+// sortPlugins orders plugins so that every plugin follows its dependencies, the register order is kept otherwise.
+func sortPlugins(plugins []kelvins.Plugin) ([]kelvins.Plugin, error) {
+	byName := make(map[string]kelvins.Plugin, len(plugins))
+	for _, p := range plugins {
+		byName[p.Name()] = p
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var sorted []kelvins.Plugin
+	var visit func(p kelvins.Plugin, path []string) error
+	visit = func(p kelvins.Plugin, path []string) error {
+		name := p.Name()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("plugin dependency cycle: %v", append(path, name))
+		}
+		state[name] = visiting
+		if deps, ok := p.(kelvins.PluginDependencies); ok {
+			for _, dep := range deps.Dependencies() {
+				depPlugin, ok := byName[dep]
+				if !ok {
+					return fmt.Errorf("plugin %s depends on %s which is not registered", name, dep)
+				}
+				if err := visit(depPlugin, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, p)
+		return nil
+	}
+	for _, p := range plugins {
+		if err := visit(p, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// initPlugins maps the config sections of the plugins then inits them in dependency order.
+func initPlugins() ([]kelvins.Plugin, error) {
+	plugins, err := sortPlugins(kelvins.Plugins())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if section, v := p.ConfigSection(); section != "" && v != nil {
+			err = config.MapConfigE(section, v)
+			if err != nil && !errors.Is(err, config.ErrSectionNotExist) {
+				return nil, fmt.Errorf("plugin %s config err: %v", p.Name(), err)
+			}
+		}
+		err = p.Init(vars.AppContext)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s init err: %v", p.Name(), err)
+		}
+		logging.Infof("App plugin %s inited\n", p.Name())
+	}
+	return plugins, nil
+}
+
+// startPlugins starts the plugins and registers their health checks.
+func startPlugins(plugins []kelvins.Plugin) error {
+	for _, p := range plugins {
+		err := p.Start(vars.AppContext)
+		if err != nil {
+			return fmt.Errorf("plugin %s start err: %v", p.Name(), err)
+		}
+		pluginsStarted = append(pluginsStarted, p)
+		health.Register(health.Check{
+			Name: "plugin-" + p.Name(),
+			Func: p.Health,
+		})
+	}
+	return nil
+}
+
+// stopPlugins stops the started plugins in the reverse order within the shutdown grace timeout and the shutdown deadline of parent.
+func stopPlugins(parent context.Context) []error {
+	if len(pluginsStarted) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(parent, kelvins.ServerSetting.GetShutdownGrace())
+	defer cancel()
+	var errs []error
+	for i := len(pluginsStarted) - 1; i >= 0; i-- {
+		p := pluginsStarted[i]
+		if err := p.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s stop err: %v", p.Name(), err))
+		}
+	}
+	pluginsStarted = nil
+	return errs
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"reflect"
 	"time"
 
 	"gitee.com/kelvins-io/common/convert"
@@ -12,6 +13,7 @@ import (
 	"gitee.com/kelvins-io/kelvins"
 	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
 	"gitee.com/kelvins-io/kelvins/util/kprocess"
 	"github.com/RichardKnop/machinery/v1"
 	queueLog "github.com/RichardKnop/machinery/v1/log"
@@ -34,51 +36,63 @@ func RunQueueApplication(application *kelvins.QueueApplication) {
 	application.Type = kelvins.AppTypeQueue
 	kelvins.QueueAppInstance = application
 
-	err := runQueue(application)
-	if err != nil {
-		logging.Infof("queueApp runQueue err: %v\n", err)
-	}
-
-	appPrepareForceExit()
-	// Wait for connections to drain.
-	err = appShutdown(application.Application)
-	if err != nil {
-		logging.Infof("queueApp appShutdown err: %v\n", err)
-	}
+	runApplication("queueApp", application.Application, &queueComponent{app: application})
 }
 
 var queueToWorker = map[*queue.MachineryQueue][]*machinery.Worker{}
 
-// runQueue runs queue application.
-func runQueue(queueApp *kelvins.QueueApplication) error {
-	// 1. init application
+// queueComponent runs queue application.
+type queueComponent struct {
+	app        *kelvins.QueueApplication
+	errorsChan chan error
+	tasks      inflightQueueTasks
+}
+
+func (c *queueComponent) name() string {
+	return "queueApp"
+}
+
+func (c *queueComponent) application() *kelvins.Application {
+	return c.app.Application
+}
+
+func (c *queueComponent) prepare(kp *kprocess.KProcess) error {
+	queueApp := c.app
 	var err error
-	err = initApplication(queueApp.Application)
-	if err != nil {
-		return err
-	}
-	if !appProcessNext {
-		return err
-	}
 
-	// 2 init queue vars
+	// 1. init queue vars
 	err = setupQueueVars(queueApp)
 	if err != nil {
 		return err
 	}
 
-	// 3. event server
+	// 2. event server
 	if kelvins.EventServerAliRocketMQ != nil {
 		logging.Info("queueApp start event server ")
 		if queueApp.RegisterEventProducer != nil {
-			appRegisterEventProducer(queueApp.RegisterEventProducer, queueApp.Type)
+			appRegisterEventProducer(queueApp.RegisterEventProducer, kelvins.AppTypeQueue)
 		}
 		if queueApp.RegisterEventHandler != nil {
-			appRegisterEventHandler(queueApp.RegisterEventHandler, queueApp.Type)
+			appRegisterEventHandler(queueApp.RegisterEventHandler, kelvins.AppTypeQueue)
+		} else if queueApp.RegisterEventHandlerCtx != nil {
+			appRegisterEventHandler(eventHandlerCtx(queueApp.RegisterEventHandlerCtx), kelvins.AppTypeQueue)
 		}
 	}
 
-	// 4. queue server
+	return nil
+}
+
+func (c *queueComponent) register() error {
+	return nil
+}
+
+func (c *queueComponent) listen(kp *kprocess.KProcess) error {
+	return nil
+}
+
+// serve launches the queue workers, worker not listen Interrupt,SIGTERM signal stop.
+func (c *queueComponent) serve(done func()) error {
+	queueApp := c.app
 	logging.Info("queueApp start queue server consume")
 	concurrency := len(queueApp.GetNamedTaskFuncs())
 	if kelvins.QueueServerSetting != nil {
@@ -87,11 +101,6 @@ func runQueue(queueApp *kelvins.QueueApplication) error {
 	logging.Infof("queueApp count of worker goroutine: %d\n", concurrency)
 	consumerTag := queueApp.Application.Name + convert.Int64ToStr(time.Now().Local().UnixNano())
 
-	kp := new(kprocess.KProcess)
-	_, err = kp.Listen("", "", kelvins.PIDFile)
-	if err != nil {
-		return fmt.Errorf("kprocess listen pidFile(%v) err: %v", kelvins.PIDFile, err)
-	}
 	var queueList []string
 	queueList = append(queueList, kelvins.QueueServerSetting.CustomQueueList...)
 	errorsChanSize := 0
@@ -105,6 +114,7 @@ func runQueue(queueApp *kelvins.QueueApplication) error {
 		errorsChanSize += len(queueList)
 	}
 	errorsChan := make(chan error, errorsChanSize)
+	c.errorsChan = errorsChan
 	for _, customQueue := range queueList {
 		cTag := consumerTag
 		if len(customQueue) > 0 {
@@ -113,40 +123,56 @@ func runQueue(queueApp *kelvins.QueueApplication) error {
 		if kelvins.QueueRedisSetting != nil && !kelvins.QueueRedisSetting.DisableConsume && kelvins.QueueServerRedis != nil {
 			logging.Infof("queueApp queueServerRedis Consumer Tag: %s\n", cTag)
 			worker := kelvins.QueueServerRedis.TaskServer.NewCustomQueueWorker(cTag, concurrency, customQueue)
+			worker.SetPreTaskHandler(c.tasks.pre)
+			worker.SetPostTaskHandler(c.tasks.post)
 			worker.LaunchAsync(errorsChan)
 			queueToWorker[kelvins.QueueServerRedis] = append(queueToWorker[kelvins.QueueServerRedis], worker)
 		}
 		if kelvins.QueueAMQPSetting != nil && !kelvins.QueueAMQPSetting.DisableConsume && kelvins.QueueServerAMQP != nil {
 			logging.Infof("queueApp queueServerAMQP Consumer Tag: %s\n", cTag)
 			worker := kelvins.QueueServerAMQP.TaskServer.NewCustomQueueWorker(cTag, concurrency, customQueue)
+			worker.SetPreTaskHandler(c.tasks.pre)
+			worker.SetPostTaskHandler(c.tasks.post)
 			worker.LaunchAsync(errorsChan)
 			queueToWorker[kelvins.QueueServerAMQP] = append(queueToWorker[kelvins.QueueServerAMQP], worker)
 		}
 		if kelvins.QueueAliAMQPSetting != nil && !kelvins.QueueAliAMQPSetting.DisableConsume && kelvins.QueueServerAliAMQP != nil {
 			logging.Infof("queueApp queueServerAliAMQP Consumer Tag: %s\n", cTag)
 			worker := kelvins.QueueServerAliAMQP.TaskServer.NewCustomQueueWorker(cTag, concurrency, customQueue)
+			worker.SetPreTaskHandler(c.tasks.pre)
+			worker.SetPostTaskHandler(c.tasks.post)
 			worker.LaunchAsync(errorsChan)
 			queueToWorker[kelvins.QueueServerAliAMQP] = append(queueToWorker[kelvins.QueueServerAliAMQP], worker)
 		}
 	}
 	queueApp.QueueServerToWorker = queueToWorker
-	<-kp.Exit() // worker not listen Interrupt,SIGTERM signal stop
+	return nil
+}
 
-	// 5 close
+func (c *queueComponent) drain() {}
+
+// stop waits for the running queue tasks.
+func (c *queueComponent) stop(ctx context.Context) error {
+	if c.errorsChan == nil {
+		return nil
+	}
+	err := inflight.wait(ctx, inflightQueue)
+	if err != nil {
+		return err
+	}
 	queueWorkerStop()
-	close(errorsChan)
+	close(c.errorsChan)
 	queueWorkerErr := bytes.Buffer{}
-	for c := range errorsChan {
+	for err := range c.errorsChan {
 		if queueWorkerErr.String() == "" {
 			queueWorkerErr.WriteString("worker err=>")
 		}
-		queueWorkerErr.WriteString(c.Error())
+		queueWorkerErr.WriteString(err.Error())
 	}
 	if queueWorkerErr.String() != "" {
-		err = fmt.Errorf(queueWorkerErr.String())
+		return fmt.Errorf(queueWorkerErr.String())
 	}
-
-	return err
+	return nil
 }
 
 // setupQueueVars ...
@@ -168,10 +194,14 @@ func setupQueueVars(queueApp *kelvins.QueueApplication) error {
 	}
 
 	// only queueApp need check GetNamedTaskFuncs or RegisterEventHandler
-	if queueApp.GetNamedTaskFuncs == nil && queueApp.RegisterEventHandler == nil {
+	if queueApp.GetNamedTaskFuncs == nil && queueApp.RegisterEventHandler == nil && queueApp.RegisterEventHandlerCtx == nil {
 		return fmt.Errorf("lack of implement GetNamedTaskFuncs And RegisterEventHandler")
 	}
-	err := setupCommonQueue(queueApp.GetNamedTaskFuncs())
+	var namedTaskFunc map[string]interface{}
+	if queueApp.GetNamedTaskFuncs != nil {
+		namedTaskFunc = contextTaskFuncs(queueApp.GetNamedTaskFuncs())
+	}
+	err := setupCommonQueue(namedTaskFunc)
 	if err != nil {
 		return err
 	}
@@ -179,6 +209,42 @@ func setupQueueVars(queueApp *kelvins.QueueApplication) error {
 	return nil
 }
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// contextTaskFuncs warps the task funcs whose first param is context.Context,
+// the task runs with a context cancelled on shutdown which keeps the values of the machinery context.
+func contextTaskFuncs(namedTaskFunc map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(namedTaskFunc))
+	for name, taskFunc := range namedTaskFunc {
+		result[name] = taskFunc
+		fn := reflect.ValueOf(taskFunc)
+		if fn.Kind() != reflect.Func || fn.Type().NumIn() == 0 || fn.Type().In(0) != contextType {
+			continue
+		}
+		result[name] = reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+			taskCtx, _ := args[0].Interface().(context.Context)
+			if taskCtx == nil {
+				taskCtx = context.Background()
+			}
+			ctx, cancel := context.WithCancel(vars.AppContext)
+			defer cancel()
+			args[0] = reflect.ValueOf(&taskContext{Context: ctx, values: taskCtx})
+			return fn.Call(args)
+		}).Interface()
+	}
+	return result
+}
+
+// taskContext has the cancellation of the app context and the values of the machinery task or event context.
+type taskContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *taskContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
 func queueWorkerStop() {
 	//for queue,worker := range queueWorker {
 	//	// process exit queue worker should exit
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"time"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/config/setting"
+	"gitee.com/kelvins-io/kelvins/internal/config"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/util/registry"
+)
+
+const defaultRegistryFilePath = "./etc/registry.json"
+
+// setupRegistry picks the registry by section kelvins-registry, a registry set by registry.SetDefault is kept.
+func setupRegistry() {
+	if registry.Default() != nil {
+		return
+	}
+	registrySetting := kelvins.RegistrySetting
+	if registrySetting == nil {
+		registrySetting = &setting.RegistrySettingS{}
+	}
+	registryType := registrySetting.Type
+	if registryType == "" {
+		registryType = setting.RegistryTypeEtcd
+	}
+	switch registryType {
+	case setting.RegistryTypeFile:
+		filePath := registrySetting.FilePath
+		if filePath == "" {
+			filePath = defaultRegistryFilePath
+		}
+		interval := time.Duration(registrySetting.WatchIntervalSecond) * time.Second
+		registry.SetDefault(registry.NewFile(filePath, interval))
+	case setting.RegistryTypeMemory:
+		registry.SetDefault(registry.NewMemory())
+	default:
+		etcdServerUrls := config.GetEtcdV3ServerURLs()
+		if etcdServerUrls == "" {
+			// the registration fails, the memory and file registries must be selected explicitly
+			logging.Errf("App registry etcd not found environment variable(%v), the service can not be registered\n", config.ENV_ETCDV3_SERVER_URLS)
+		}
+		etcd := registry.NewEtcd(etcdServerUrls, kelvins.ServerSetting.GetRegisterTTL())
+		// the clients keep routing to the last known instances while etcd is unavailable
+		registry.SetDefault(registry.NewCache(etcd, registrySetting.SnapshotPath))
+	}
+	logging.Infof("App registry selected [%s]\n", registryType)
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gitee.com/kelvins-io/common/log"
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/config/setting"
+	"gitee.com/kelvins-io/kelvins/internal/config"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+)
+
+const defaultConfigWatchInterval = 5 * time.Second
+
+var (
+	loggerLevelPinned bool
+	loggerLevelMutex  sync.Mutex
+)
+
+// setupConfigReload watches the config file and the remote config, and applies the settings which can change at runtime.
+func setupConfigReload(application *kelvins.Application) {
+	config.OnChange(config.SectionLogger, func(section string, value interface{}) {
+		if loggerSetting, ok := value.(*setting.LoggerSettingS); ok && loggerSetting != nil {
+			reloadLoggerLevel(application, loggerSetting)
+		}
+	})
+
+	interval := defaultConfigWatchInterval
+	if kelvins.ConfigSetting != nil {
+		if kelvins.ConfigSetting.WatchIntervalSecond > 0 {
+			interval = time.Duration(kelvins.ConfigSetting.WatchIntervalSecond) * time.Second
+		}
+		if kelvins.ConfigSetting.WatchDisable {
+			interval = 0
+		}
+	}
+	go config.Watch(interval, appCloseCh)
+	if kelvins.ConfigSetting != nil && kelvins.ConfigSetting.RemoteEnable {
+		go config.WatchRemote(appCloseCh)
+	}
+}
+
+// reloadLoggerLevel re-initializes the global loggers with the new level of section kelvins-logger.
+func reloadLoggerLevel(application *kelvins.Application, loggerSetting *setting.LoggerSettingS) {
+	if loggerLevelPinned {
+		return
+	}
+	loggerLevel := DefaultLoggerLevel
+	if loggerSetting.Level != "" {
+		loggerLevel = loggerSetting.Level
+	}
+	err := setLoggerLevel(application, loggerLevel)
+	if err != nil {
+		logging.Errf("reload logger level(%v) err: %v\n", loggerLevel, err)
+	}
+}
+
+// setLoggerLevel re-initializes the global loggers with loggerLevel, it is used by config reload and the admin server.
+func setLoggerLevel(application *kelvins.Application, loggerLevel string) error {
+	loggerLevelMutex.Lock()
+	defer loggerLevelMutex.Unlock()
+	if loggerLevel == application.LoggerLevel {
+		return nil
+	}
+	err := log.InitGlobalConfig(application.LoggerRootPath, loggerLevel, application.Name)
+	if err != nil {
+		return fmt.Errorf("log.InitGlobalConfig err: %v", err)
+	}
+	err = setupLoggers()
+	if err != nil {
+		return fmt.Errorf("setupLoggers err: %v", err)
+	}
+	logging.Infof("logger level changed %v => %v\n", application.LoggerLevel, loggerLevel)
+	application.LoggerLevel = loggerLevel
+	return nil
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitee.com/kelvins-io/common/log"
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/config"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
+	"gitee.com/kelvins-io/kelvins/setup"
+	"gitee.com/kelvins-io/kelvins/util/goroutine"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	resourceMutex  sync.Mutex
+	resourceInited []kelvins.Resource // in init order
+	resourceStatus = map[string]resourceCheckStatus{}
+
+	resourceUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kelvins_resource_up",
+		Help: "Whether the last health check of the resource succeeded.",
+	}, []string{"name", "kind"})
+)
+
+type resourceCheckStatus struct {
+	Kind      string `json:"kind"`
+	Up        bool   `json:"up"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt string `json:"checked_at"`
+}
+
+func init() {
+	prometheus.MustRegister(resourceUp)
+	expvar.Publish("resources", expvar.Func(func() interface{} {
+		resourceMutex.Lock()
+		defer resourceMutex.Unlock()
+		stats := make(map[string]resourceCheckStatus, len(resourceStatus))
+		for name, status := range resourceStatus {
+			stats[name] = status
+		}
+		return stats
+	}))
+}
+
+// registerFrameworkResources registers the clients of the configured framework sections.
+func registerFrameworkResources(application *kelvins.Application) {
+	if kelvins.MysqlSetting != nil && kelvins.MysqlSetting.Host != "" {
+		kelvins.RegisterResource(kelvins.Resource{
+			Name: config.SectionMysql,
+			Kind: "mysql",
+			Init: func() error {
+				kelvins.MysqlSetting.LoggerLevel = application.LoggerLevel
+				kelvins.MysqlSetting.Environment = application.Environment
+				logger, err := log.GetCustomLogger("db-log", "mysql")
+				if err != nil {
+					return err
+				}
+				kelvins.MysqlSetting.Logger = logger
+				kelvins.GORM_DBEngine, err = setup.NewMySQLWithGORM(kelvins.MysqlSetting)
+				if err != nil {
+					return err
+				}
+				kelvins.XORM_DBEngine, err = setup.NewMySQLWithXORM(kelvins.MysqlSetting)
+				return err
+			},
+			Check: func(ctx context.Context) error {
+				return kelvins.GORM_DBEngine.DB().PingContext(ctx)
+			},
+			Close: func(ctx context.Context) error {
+				if closer, ok := kelvins.XORM_DBEngine.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+				return kelvins.GORM_DBEngine.Close()
+			},
+		})
+	}
+
+	if kelvins.MongoDBSetting != nil && kelvins.MongoDBSetting.Uri != "" {
+		kelvins.RegisterResource(kelvins.Resource{
+			Name: config.SectionMongoDB,
+			Kind: "mongodb",
+			Init: func() (err error) {
+				kelvins.MongoDBClient, err = setup.NewMongoDBClient(kelvins.MongoDBSetting)
+				return err
+			},
+			Check: func(ctx context.Context) error {
+				timeout := int64(kelvins.ReadinessSetting.GetTimeout() / time.Second)
+				return withContext(ctx, func() error {
+					return kelvins.MongoDBClient.Ping(timeout)
+				})
+			},
+			Close: func(ctx context.Context) error {
+				return kelvins.MongoDBClient.Close(ctx)
+			},
+		})
+	}
+
+	if kelvins.RedisSetting != nil && kelvins.RedisSetting.Host != "" {
+		kelvins.RegisterResource(kelvins.Resource{
+			Name: config.SectionRedis,
+			Kind: "redis",
+			Init: func() (err error) {
+				kelvins.RedisConn, err = setup.NewRedis(kelvins.RedisSetting)
+				return err
+			},
+			Check: func(ctx context.Context) error {
+				return withContext(ctx, func() error {
+					conn := kelvins.RedisConn.Get()
+					defer conn.Close()
+					_, err := conn.Do("PING")
+					return err
+				})
+			},
+			Close: func(ctx context.Context) error {
+				return kelvins.RedisConn.Close()
+			},
+		})
+	}
+
+	if kelvins.GPoolSetting != nil && kelvins.GPoolSetting.JobChanLen > 0 && kelvins.GPoolSetting.WorkerNum > 0 {
+		kelvins.RegisterResource(kelvins.Resource{
+			Name: config.SectionGPool,
+			Kind: "gpool",
+			Init: func() error {
+				kelvins.GPool = goroutine.NewPoolWithContext(vars.AppContext, kelvins.GPoolSetting.WorkerNum, kelvins.GPoolSetting.JobChanLen)
+				return nil
+			},
+			Close: func(ctx context.Context) error {
+				kelvins.GPool.Release()
+				kelvins.GPool.WaitAll()
+				return nil
+			},
+		})
+	}
+
+	if kelvins.G2CacheSetting != nil && kelvins.G2CacheSetting.RedisConfDSN != "" {
+		g2cacheSetting := kelvins.G2CacheSetting
+		kelvins.RegisterResource(kelvins.Resource{
+			Name: config.SectionG2cache,
+			Kind: "g2cache",
+			Init: func() (err error) {
+				kelvins.G2CacheEngine, err = setup.NewG2Cache(g2cacheSetting, nil, nil)
+				return err
+			},
+			Check: func(ctx context.Context) error {
+				return pingRedis(ctx, g2cacheSetting.RedisConfDSN, g2cacheSetting.RedisConfPwd, g2cacheSetting.RedisConfDB)
+			},
+		})
+	}
+}
+
+// registerQueueResource registers a queue server created by setupCommonQueue, the broker is checked by dial.
+func registerQueueResource(section, broker, defaultPort string) {
+	kelvins.RegisterResource(kelvins.Resource{
+		Name: section,
+		Kind: "queue",
+		Check: func(ctx context.Context) error {
+			return dialBroker(ctx, broker, defaultPort)
+		},
+	})
+}
+
+// initResources inits the registered resources which are not inited yet, in register order.
+func initResources() error {
+	for _, resource := range kelvins.Resources() {
+		resourceMutex.Lock()
+		inited := false
+		for _, r := range resourceInited {
+			if r.Name == resource.Name {
+				inited = true
+				break
+			}
+		}
+		resourceMutex.Unlock()
+		if inited {
+			continue
+		}
+		if resource.Init != nil {
+			if err := resource.Init(); err != nil {
+				return fmt.Errorf("resource %s init err: %v", resource.Name, err)
+			}
+		}
+		resourceMutex.Lock()
+		resourceInited = append(resourceInited, resource)
+		resourceMutex.Unlock()
+	}
+	return nil
+}
+
+// resourceChecks returns the inited resources which have a health check,
+// the check records its result for the metrics.
+func resourceChecks() []kelvins.Resource {
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+	var result []kelvins.Resource
+	for _, resource := range resourceInited {
+		if resource.Check == nil {
+			continue
+		}
+		check, name, kind := resource.Check, resource.Name, resource.Kind
+		resource.Check = func(ctx context.Context) error {
+			err := check(ctx)
+			recordResourceCheck(name, kind, err)
+			return err
+		}
+		result = append(result, resource)
+	}
+	return result
+}
+
+func recordResourceCheck(name, kind string, err error) {
+	status := resourceCheckStatus{Kind: kind, Up: err == nil, CheckedAt: time.Now().Format(kelvins.ResponseTimeLayout)}
+	up := 1.0
+	if err != nil {
+		status.Error = err.Error()
+		up = 0
+	}
+	resourceUp.WithLabelValues(name, kind).Set(up)
+	resourceMutex.Lock()
+	resourceStatus[name] = status
+	resourceMutex.Unlock()
+}
+
+// closeResources closes the inited resources in the reverse order of init within the shutdown deadline of ctx,
+// every resource is closed even if one fails.
+func closeResources(ctx context.Context) []error {
+	resourceMutex.Lock()
+	inited := resourceInited
+	resourceInited = nil
+	resourceMutex.Unlock()
+	var errs []error
+	for i := len(inited) - 1; i >= 0; i-- {
+		resource := inited[i]
+		if resource.Close == nil {
+			continue
+		}
+		if err := resource.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("resource %s close err: %v", resource.Name, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/util/health"
+	"github.com/RichardKnop/machinery/v1/tasks"
+	"google.golang.org/grpc"
+)
+
+const (
+	inflightRPC    = "rpc"
+	inflightHTTP   = "http"
+	inflightCron   = "cron"
+	inflightQueue  = "queue"
+	inflightWorker = "worker"
+)
+
+// inflight tracks the running rpc, http request, cron job and queue task,
+// they are logged when the shutdown grace timeout is exceeded.
+var inflight = &inflightWork{items: map[uint64]inflightItem{}}
+
+type inflightItem struct {
+	kind  string
+	name  string
+	start time.Time
+}
+
+type inflightWork struct {
+	mutex sync.Mutex
+	seq   uint64
+	items map[uint64]inflightItem
+}
+
+// begin records a running work, the returned func must be called when it is finished.
+func (w *inflightWork) begin(kind, name string) func() {
+	w.mutex.Lock()
+	w.seq++
+	id := w.seq
+	w.items[id] = inflightItem{kind: kind, name: name, start: time.Now()}
+	w.mutex.Unlock()
+	return func() {
+		w.mutex.Lock()
+		delete(w.items, id)
+		w.mutex.Unlock()
+	}
+}
+
+// count returns the number of running work of kind.
+func (w *inflightWork) count(kind string) int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	n := 0
+	for _, item := range w.items {
+		if item.kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// running describes the running work, the longest first.
+func (w *inflightWork) running() []string {
+	w.mutex.Lock()
+	items := make([]inflightItem, 0, len(w.items))
+	for _, item := range w.items {
+		items = append(items, item)
+	}
+	w.mutex.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].start.Before(items[j].start)
+	})
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, fmt.Sprintf("%s %s %v", item.kind, item.name, time.Since(item.start).Truncate(time.Millisecond)))
+	}
+	return result
+}
+
+// wait waits until no work of kind is running or ctx is done.
+func (w *inflightWork) wait(ctx context.Context, kind string) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for w.count(kind) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d %s still running: %v", w.count(kind), kind, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func logInflight(reason string) {
+	running := inflight.running()
+	if len(running) == 0 {
+		logging.Infof("App %s, no work is running\n", reason)
+		return
+	}
+	logging.Infof("App %s, %d still running: %s\n", reason, len(running), strings.Join(running, ", "))
+}
+
+func inflightUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	defer inflight.begin(inflightRPC, info.FullMethod)()
+	return handler(ctx, req)
+}
+
+func inflightStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	defer inflight.begin(inflightRPC, info.FullMethod)()
+	return handler(srv, ss)
+}
+
+func inflightHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer inflight.begin(inflightHTTP, r.Method+" "+r.URL.Path)()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// inflightQueueTasks tracks the tasks of the queue workers by the pre and post task handler.
+type inflightQueueTasks struct {
+	ends sync.Map // task uuid => end func
+}
+
+func (q *inflightQueueTasks) pre(signature *tasks.Signature) {
+	q.ends.Store(signature.UUID, inflight.begin(inflightQueue, signature.Name))
+}
+
+func (q *inflightQueueTasks) post(signature *tasks.Signature) {
+	if end, ok := q.ends.Load(signature.UUID); ok {
+		q.ends.Delete(signature.UUID)
+		end.(func())()
+	}
+}
+
+// shutdownDrain flips /readyz and the components to not serving, then waits the drain period for the clients to notice.
+func shutdownDrain(ctx context.Context, components []component) {
+	health.SetShutdown()
+	for _, c := range components {
+		c.drain()
+	}
+	drain := kelvins.ServerSetting.GetShutdownDrain()
+	if drain > 0 && len(components) > 0 {
+		logging.Infof("App shutdown drain %v\n", drain)
+		timer := time.NewTimer(drain)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// shutdownStop stops the components in order within the grace timeout and the shutdown deadline of parent,
+// a component still running at the deadline is stopped by force.
+func shutdownStop(parent context.Context, components []component) []error {
+	grace := kelvins.ServerSetting.GetShutdownGrace()
+	ctx, cancel := context.WithTimeout(parent, grace)
+	defer cancel()
+	var errs []error
+	for _, c := range components {
+		if err := c.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s stop err: %v", c.name(), err))
+		}
+	}
+	if ctx.Err() != nil {
+		logInflight(fmt.Sprintf("shutdown grace timeout %v exceeded, force stop", grace))
+	}
+	return errs
+}
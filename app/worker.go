@@ -0,0 +1,319 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
+	"gitee.com/kelvins-io/kelvins/util/health"
+	"gitee.com/kelvins-io/kelvins/util/kprocess"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultWorkerMinBackoff = time.Second
+	defaultWorkerMaxBackoff = 30 * time.Second
+	// workerUnhealthyFailures is the consecutive failures after which the loop health check fails
+	workerUnhealthyFailures = 3
+)
+
+var (
+	workerRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kelvins_worker_running",
+		Help: "Number of the running goroutines of the worker loop.",
+	}, []string{"name"})
+	workerRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kelvins_worker_restarts_total",
+		Help: "Number of the restarts of the worker loop after an error or panic.",
+	}, []string{"name"})
+)
+
+func init() {
+	prometheus.MustRegister(workerRunning, workerRestarts)
+}
+
+// RunWorkerApplication runs worker application.
+func RunWorkerApplication(application *kelvins.WorkerApplication) {
+	if application == nil || application.Application == nil {
+		panic("workerApplication is nil or application is nil")
+	}
+	// app instance once validate
+	{
+		err := appInstanceOnceValidate()
+		if err != nil {
+			logging.Fatal(err.Error())
+		}
+	}
+
+	application.Type = kelvins.AppTypeWorker
+	kelvins.WorkerAppInstance = application
+
+	runApplication("workerApp", application.Application, &workerComponent{app: application})
+}
+
+// workerComponent runs the worker loops.
+type workerComponent struct {
+	app    *kelvins.WorkerApplication
+	loops  []*workerLoop
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (c *workerComponent) name() string {
+	return "workerApp"
+}
+
+func (c *workerComponent) application() *kelvins.Application {
+	return c.app.Application
+}
+
+// prepare checks the worker loops.
+func (c *workerComponent) prepare(kp *kprocess.KProcess) error {
+	workerApp := c.app
+
+	// 1. init worker vars
+	err := setupCommonQueue(nil)
+	if err != nil {
+		return err
+	}
+
+	// 2. register event handler
+	if kelvins.EventServerAliRocketMQ != nil {
+		logging.Info("workerApp Start event server")
+		if workerApp.RegisterEventProducer != nil {
+			appRegisterEventProducer(workerApp.RegisterEventProducer, kelvins.AppTypeWorker)
+		}
+		if workerApp.RegisterEventHandler != nil {
+			appRegisterEventHandler(workerApp.RegisterEventHandler, kelvins.AppTypeWorker)
+		} else if workerApp.RegisterEventHandlerCtx != nil {
+			appRegisterEventHandler(eventHandlerCtx(workerApp.RegisterEventHandlerCtx), kelvins.AppTypeWorker)
+		}
+	}
+
+	// 3. check worker loops
+	if workerApp.GenWorkerLoops == nil {
+		return fmt.Errorf("lack of implement GenWorkerLoops")
+	}
+	minBackoff, maxBackoff := workerApp.MinBackoff, workerApp.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultWorkerMinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = defaultWorkerMaxBackoff
+		if maxBackoff < minBackoff {
+			maxBackoff = minBackoff
+		}
+	}
+	loopNameDict := map[string]int{}
+	total := 0
+	for _, l := range workerApp.GenWorkerLoops() {
+		if l.Name == "" {
+			return fmt.Errorf("lack of WorkerLoop.Name")
+		}
+		if l.Loop == nil {
+			return fmt.Errorf("lack of WorkerLoop.Loop")
+		}
+		if _, ok := loopNameDict[l.Name]; ok {
+			return fmt.Errorf("repeat loop name: %s", l.Name)
+		}
+		loopNameDict[l.Name] = 1
+		concurrency := l.Concurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+		total += concurrency
+		c.loops = append(c.loops, &workerLoop{
+			name:        l.Name,
+			loop:        l.Loop,
+			concurrency: concurrency,
+			minBackoff:  minBackoff,
+			maxBackoff:  maxBackoff,
+		})
+	}
+	if len(c.loops) == 0 {
+		return fmt.Errorf("GenWorkerLoops returns no loop")
+	}
+	if workerApp.MaxConcurrency > 0 && total > workerApp.MaxConcurrency {
+		return fmt.Errorf("worker loops concurrency %d exceeds MaxConcurrency %d", total, workerApp.MaxConcurrency)
+	}
+
+	return nil
+}
+
+// register registers the health checks of the loops.
+func (c *workerComponent) register() error {
+	for _, l := range c.loops {
+		health.Register(health.Check{
+			Name: "worker-" + l.name,
+			Func: l.check,
+		})
+	}
+	return nil
+}
+
+func (c *workerComponent) listen(kp *kprocess.KProcess) error {
+	return nil
+}
+
+// serve starts the loops, done is called when every loop returned by itself.
+func (c *workerComponent) serve(done func()) error {
+	logging.Info("workerApp Start worker loops")
+	ctx, cancel := context.WithCancel(vars.AppContext)
+	c.cancel = cancel
+	for _, l := range c.loops {
+		for i := 0; i < l.concurrency; i++ {
+			c.wg.Add(1)
+			go func(l *workerLoop) {
+				defer c.wg.Done()
+				l.run(ctx)
+			}(l)
+		}
+	}
+	go func() {
+		c.wg.Wait()
+		if ctx.Err() == nil {
+			logging.Info("workerApp every worker loop returned")
+			done()
+		}
+	}()
+	return nil
+}
+
+func (c *workerComponent) drain() {}
+
+// stop cancels the loops and waits for them to return.
+func (c *workerComponent) stop(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		logging.Info("workerApp worker loops stop over")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d worker loop still running: %v", inflight.count(inflightWorker), ctx.Err())
+	}
+}
+
+// workerLoop runs a WorkerLoop and restarts it with backoff.
+type workerLoop struct {
+	name        string
+	loop        func(ctx context.Context) error
+	concurrency int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+
+	mutex    sync.Mutex
+	failures int
+	lastErr  error
+	// runningSince is the start of the running runOnce, zero between a failure and the restart
+	runningSince time.Time
+}
+
+// run runs the loop until ctx is done or the loop returns nil.
+func (l *workerLoop) run(ctx context.Context) {
+	backoff := l.minBackoff
+	for {
+		start := time.Now()
+		l.recordStart(start)
+		err := l.runOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			logging.Infof("workerApp loop %s returned\n", l.name)
+			return
+		}
+		wait, next, recovered := l.restartBackoff(backoff, time.Since(start))
+		if recovered {
+			l.recordRecovered()
+		}
+		l.recordFailure(err)
+		workerRestarts.WithLabelValues(l.name).Inc()
+		if kelvins.ErrLogger != nil {
+			kelvins.ErrLogger.Errorf(ctx, "workerApp loop %s err: %v, restart after %v", l.name, err, wait)
+		} else {
+			logging.Infof("workerApp loop %s err: %v, restart after %v\n", l.name, err, wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		backoff = next
+	}
+}
+
+// restartBackoff returns the wait before restarting a loop which failed after running ran, and the backoff of the next failure.
+// a loop which ran longer than maxBackoff is considered recovered, the backoff is reset to minBackoff.
+func (l *workerLoop) restartBackoff(backoff, ran time.Duration) (wait, next time.Duration, recovered bool) {
+	if ran > l.maxBackoff {
+		backoff = l.minBackoff
+		recovered = true
+	}
+	next = backoff * 2
+	if next > l.maxBackoff {
+		next = l.maxBackoff
+	}
+	return backoff, next, recovered
+}
+
+func (l *workerLoop) runOnce(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v, stack: %s", r, debug.Stack())
+		}
+	}()
+	defer inflight.begin(inflightWorker, l.name)()
+	running := workerRunning.WithLabelValues(l.name)
+	running.Inc()
+	defer running.Dec()
+	return l.loop(ctx)
+}
+
+func (l *workerLoop) recordStart(start time.Time) {
+	l.mutex.Lock()
+	l.runningSince = start
+	l.mutex.Unlock()
+}
+
+func (l *workerLoop) recordFailure(err error) {
+	l.mutex.Lock()
+	l.failures++
+	l.lastErr = err
+	l.runningSince = time.Time{}
+	l.mutex.Unlock()
+}
+
+func (l *workerLoop) recordRecovered() {
+	l.mutex.Lock()
+	l.failures = 0
+	l.lastErr = nil
+	l.mutex.Unlock()
+}
+
+// check fails when the loop failed workerUnhealthyFailures times in a row,
+// the failures are reset once the restarted loop runs longer than maxBackoff.
+func (l *workerLoop) check(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.runningSince.IsZero() && time.Since(l.runningSince) > l.maxBackoff {
+		l.failures = 0
+		l.lastErr = nil
+	}
+	if l.failures >= workerUnhealthyFailures {
+		return fmt.Errorf("failed %d times in a row, last err: %v", l.failures, l.lastErr)
+	}
+	return nil
+}
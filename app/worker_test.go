@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerLoop_RestartBackoff(t *testing.T) {
+	l := &workerLoop{minBackoff: time.Second, maxBackoff: 8 * time.Second}
+	cases := []struct {
+		name      string
+		ran       time.Duration
+		wait      time.Duration
+		recovered bool
+	}{
+		{"first failure", 0, time.Second, false},
+		{"doubled", 0, 2 * time.Second, false},
+		{"doubled again", time.Second, 4 * time.Second, false},
+		{"reached max", 0, 8 * time.Second, false},
+		{"capped at max", 8 * time.Second, 8 * time.Second, false},
+		{"reset after a long run", 9 * time.Second, time.Second, true},
+		{"doubled after reset", 0, 2 * time.Second, false},
+	}
+	backoff := l.minBackoff
+	for _, c := range cases {
+		wait, next, recovered := l.restartBackoff(backoff, c.ran)
+		if wait != c.wait || recovered != c.recovered {
+			t.Fatalf("%s: restartBackoff(%v, %v) = %v, %v, expect %v, %v", c.name, backoff, c.ran, wait, recovered, c.wait, c.recovered)
+		}
+		backoff = next
+	}
+}
+
+func TestWorkerLoop_CheckResetsAfterLongRun(t *testing.T) {
+	l := &workerLoop{minBackoff: time.Second, maxBackoff: 8 * time.Second}
+	for i := 0; i < workerUnhealthyFailures; i++ {
+		l.recordFailure(errors.New("loop err"))
+	}
+	if err := l.check(context.Background()); err == nil {
+		t.Fatal("expect unhealthy after consecutive failures")
+	}
+	l.recordStart(time.Now())
+	if err := l.check(context.Background()); err == nil {
+		t.Fatal("expect unhealthy right after the restart")
+	}
+	l.recordStart(time.Now().Add(-9 * time.Second))
+	if err := l.check(context.Background()); err != nil {
+		t.Fatalf("expect healthy after running longer than maxBackoff, got %v", err)
+	}
+	l.recordFailure(errors.New("loop err"))
+	if err := l.check(context.Background()); err != nil {
+		t.Fatalf("one failure after the reset should be healthy, got %v", err)
+	}
+}
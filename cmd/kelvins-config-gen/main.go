@@ -0,0 +1,104 @@
+// kelvins-config-gen writes a commented sample config and a JSON Schema of every kelvins-* section,
+// the comments are read from config/setting/setting.go when it can be found.
+//
+//	kelvins-config-gen -format ini -out ./etc
+//	kelvins-config-gen -format yaml -setting $GOPATH/src/gitee.com/kelvins-io/kelvins/config/setting/setting.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitee.com/kelvins-io/kelvins/config"
+)
+
+var (
+	flagFormat  = flag.String("format", "ini", "sample config format eg: ini yaml")
+	flagOut     = flag.String("out", ".", "output dir of app.sample.<format> and app.schema.json")
+	flagSetting = flag.String("setting", "config/setting/setting.go", "setting source file which the comments are read from")
+)
+
+func main() {
+	flag.Parse()
+
+	descriptions, err := readDescriptions(*flagSetting)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read comments from %s err: %v, the sample has no comments\n", *flagSetting, err)
+	}
+
+	sample, err := config.Sample(*flagFormat, descriptions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	schema, err := config.JSONSchema(descriptions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	files := map[string][]byte{
+		"app.sample." + strings.TrimPrefix(*flagFormat, "."): sample,
+		"app.schema.json": schema,
+	}
+	for name, data := range files {
+		filename := filepath.Join(*flagOut, name)
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(filename)
+	}
+}
+
+// readDescriptions collects the doc and line comments of the setting structs keyed by type or type.field.
+func readDescriptions(filename string) (map[string]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	descriptions := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			st, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			if text := commentText(gen.Doc, typeSpec.Doc); text != "" {
+				descriptions[typeSpec.Name.Name] = text
+			}
+			for _, field := range st.Fields.List {
+				text := commentText(field.Doc, field.Comment)
+				if text == "" {
+					continue
+				}
+				for _, name := range field.Names {
+					descriptions[typeSpec.Name.Name+"."+name.Name] = text
+				}
+			}
+		}
+	}
+	return descriptions, nil
+}
+
+func commentText(groups ...*ast.CommentGroup) string {
+	var parts []string
+	for _, group := range groups {
+		if text := strings.TrimSpace(group.Text()); text != "" {
+			parts = append(parts, strings.Join(strings.Fields(text), " "))
+		}
+	}
+	return strings.Join(parts, " ")
+}
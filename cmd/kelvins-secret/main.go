@@ -0,0 +1,77 @@
+// kelvins-secret encrypts config values into ENC(...) which kelvins decrypts when loading the config.
+//
+//	KELVINS_SECRET_KEY=xxx kelvins-secret my-password
+//	kelvins-secret -key_file /etc/kelvins/secret.key -d "ENC(...)"
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gitee.com/kelvins-io/kelvins/config"
+)
+
+var (
+	flagKey     = flag.String("key", "", "secret key, default read from env KELVINS_SECRET_KEY")
+	flagKeyFile = flag.String("key_file", "", "secret key file, default read from env KELVINS_SECRET_KEY_FILE")
+	flagDecrypt = flag.Bool("d", false, "decrypt ENC(...) values instead of encrypting")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-key key | -key_file file] [-d] [value ...]\nvalues are read from stdin line by line when absent\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	key, err := loadKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	values := flag.Args()
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			values = append(values, scanner.Text())
+		}
+	}
+	for _, value := range values {
+		var result string
+		if *flagDecrypt {
+			result, err = config.DecryptSecret(key, value)
+		} else {
+			result, err = config.EncryptSecret(key, value)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(result)
+	}
+}
+
+func loadKey() ([]byte, error) {
+	key := *flagKey
+	keyFile := *flagKeyFile
+	if key == "" && keyFile == "" {
+		key = os.Getenv(config.ENV_SECRET_KEY)
+		keyFile = os.Getenv(config.ENV_SECRET_KEY_FILE)
+	}
+	if key != "" {
+		return []byte(key), nil
+	}
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read key file err: %v", err)
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	}
+	return nil, fmt.Errorf("secret key is not set, use -key, -key_file or env %s", config.ENV_SECRET_KEY)
+}
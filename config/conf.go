@@ -1,10 +1,213 @@
 package config
 
 import (
+	"time"
+
 	"gitee.com/kelvins-io/kelvins/internal/config"
 )
 
-// MapConfig loads config to struct v.
+// Source describes where the effective value of a config key came from.
+type Source = config.Source
+
+const (
+	SourceDefault = config.SourceDefault
+	SourceFile    = config.SourceFile
+	SourceRemote  = config.SourceRemote
+	SourceEnv     = config.SourceEnv
+	SourceFlag    = config.SourceFlag
+)
+
+// MapConfig loads config to struct v, then validates v by its validate struct tags.
+// problems found during startup are reported together before the app starts.
 func MapConfig(section string, v interface{}) {
 	config.MapConfig(section, v)
 }
+
+// MapConfigE loads config to struct v like MapConfig, but returns the problems instead of exiting the process.
+// errors.Is(err, ErrSectionNotExist) reports an absent section, so optional sections can be skipped.
+func MapConfigE(section string, v interface{}) error {
+	return config.MapConfigE(section, v)
+}
+
+// ErrSectionNotExist is returned by MapConfigE when the section is absent.
+var ErrSectionNotExist = config.ErrSectionNotExist
+
+// Exists reports whether section is set in the config.
+func Exists(section string) bool {
+	return config.Exists(section)
+}
+
+// Sections returns the sorted names of the sections set in the config.
+func Sections() []string {
+	return config.Sections()
+}
+
+// Get returns the effective value of section.key, the bool reports whether the key is set.
+func Get(section, key string) (string, bool) {
+	return config.Get(section, key)
+}
+
+// GetString returns the value of section.key, or def when the key is not set.
+func GetString(section, key, def string) string {
+	return config.GetString(section, key, def)
+}
+
+// GetInt returns the value of section.key as int, or def when the key is not set or invalid.
+func GetInt(section, key string, def int) int {
+	return config.GetInt(section, key, def)
+}
+
+// GetBool returns the value of section.key as bool, or def when the key is not set or invalid.
+func GetBool(section, key string, def bool) bool {
+	return config.GetBool(section, key, def)
+}
+
+// GetDuration returns the value of section.key as duration eg: 3s 2h, or def when the key is not set or invalid.
+func GetDuration(section, key string, def time.Duration) time.Duration {
+	return config.GetDuration(section, key, def)
+}
+
+// GetStringSlice returns the comma separated value of section.key, or def when the key is not set.
+func GetStringSlice(section, key string, def []string) []string {
+	return config.GetStringSlice(section, key, def)
+}
+
+// ValidationError reports every problem found in the config.
+type ValidationError = config.ValidationError
+
+// Validate checks the fields of the struct v against their validate tags
+// eg: `validate:"required"` `validate:"min=1,max=10"` `validate:"oneof=a b c"` `validate:"duration"` `validate:"required_with=Field"`
+func Validate(section string, v interface{}) error {
+	return config.Validate(section, v)
+}
+
+// ParseFunc parses config file content into a Provider.
+type ParseFunc = config.ParseFunc
+
+// Provider is a parsed config document.
+type Provider = config.Provider
+
+// RegisterFormat registers a parser for config files with the extension ext eg: ".hcl"
+func RegisterFormat(ext string, parse ParseFunc) {
+	config.RegisterFormat(ext, parse)
+}
+
+// SetDefault registers the default value of section.key, call it before MapConfig.
+func SetDefault(section, key, value string) {
+	config.SetDefault(section, key, value)
+}
+
+// Origin reports where the effective value of section.key came from.
+func Origin(section, key string) Source {
+	return config.Origin(section, key)
+}
+
+// Origins reports the source of every mapped key, grouped by section.
+func Origins() map[string]map[string]Source {
+	return config.Origins()
+}
+
+// EnvName returns the env var name which overrides section.key eg: KELVINS_MYSQL_PASSWORD
+func EnvName(section, key string) string {
+	return config.EnvName(section, key)
+}
+
+// OnChange registers fn which is called after section changed by a config reload.
+// value is the new copy of the section eg: *setting.JwtSettingS, or of the type passed to MapConfig, nil when the section is not mapped.
+// the kelvins.*Setting globals and the structs passed to MapConfig keep the values loaded at startup.
+func OnChange(section string, fn func(section string, value interface{})) {
+	config.OnChange(section, fn)
+}
+
+// Setting returns the latest value of section, loaded at startup or by the last reload, nil when the section is not mapped.
+// it is safe for concurrent use, the returned value must not be modified.
+func Setting(section string) interface{} {
+	return config.Setting(section)
+}
+
+// Reload re-parses the config file and notifies OnChange handlers of changed sections.
+func Reload() error {
+	return config.Reload()
+}
+
+// RemoteRelease is one published version of the remote config.
+type RemoteRelease = config.RemoteRelease
+
+// PublishRemote publishes content as the next version of the remote config of appName in environment.
+// format is a file extension eg: .ini .yaml, the etcd endpoints are read from env ETCDV3_SERVER_URLS.
+func PublishRemote(appName, environment, format string, content []byte) (*RemoteRelease, error) {
+	return config.PublishRemote(appName, environment, format, content)
+}
+
+// RollbackRemote publishes the content of version again as the next version.
+func RollbackRemote(appName, environment string, version int64) (*RemoteRelease, error) {
+	return config.RollbackRemote(appName, environment, version)
+}
+
+// RemoteReleases lists every published version of the remote config, oldest first.
+func RemoteReleases(appName, environment string) ([]*RemoteRelease, error) {
+	return config.RemoteReleases(appName, environment)
+}
+
+const (
+	// ENV_SECRET_KEY is the env var holding the key which decrypts ENC(...) config values
+	ENV_SECRET_KEY = config.ENV_SECRET_KEY
+	// ENV_SECRET_KEY_FILE is the env var naming the key file, used when KELVINS_SECRET_KEY is empty
+	ENV_SECRET_KEY_FILE = config.ENV_SECRET_KEY_FILE
+)
+
+// SecretResolver returns the secret referenced by ref, ref is the config value without the scheme:// prefix.
+type SecretResolver = config.SecretResolver
+
+// RegisterSecretResolver registers resolver for config values like scheme://ref, file:// and env:// are built in.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	config.RegisterSecretResolver(scheme, resolver)
+}
+
+// EnableSecretScheme resolves the scheme://ref values of section.key, only ENC(...) is decrypted for other keys.
+// file:// and env:// are never resolved for the remote config.
+func EnableSecretScheme(section, key string) {
+	config.EnableSecretScheme(section, key)
+}
+
+// EncryptSecret encrypts plaintext by AES-GCM and returns ENC(...) for config files.
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	return config.EncryptSecret(key, plaintext)
+}
+
+// DecryptSecret decrypts a value returned by EncryptSecret.
+func DecryptSecret(key []byte, value string) (string, error) {
+	return config.DecryptSecret(key, value)
+}
+
+// Dump returns the effective config grouped by section, sensitive values are masked.
+func Dump() map[string]interface{} {
+	return config.Dump()
+}
+
+// DumpJSON returns Dump as indented JSON.
+func DumpJSON() ([]byte, error) {
+	return config.DumpJSON()
+}
+
+// SectionSchema describes a framework section and the setting struct it maps to.
+type SectionSchema = config.SectionSchema
+
+// FieldSchema describes a key of a section.
+type FieldSchema = config.FieldSchema
+
+// Schema reflects over the setting structs of the framework sections.
+func Schema() []SectionSchema {
+	return config.Schema()
+}
+
+// Sample renders a commented sample config of every framework section, format is .ini or .yaml.
+// descriptions holds the comments keyed by type or type.field eg: MysqlSettingS.Host, it may be nil.
+func Sample(format string, descriptions map[string]string) ([]byte, error) {
+	return config.Sample(format, descriptions)
+}
+
+// JSONSchema renders a JSON Schema of the framework sections for editors validating yaml or json config files.
+func JSONSchema(descriptions map[string]string) ([]byte, error) {
+	return config.JSONSchema(descriptions)
+}
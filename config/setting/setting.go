@@ -2,14 +2,92 @@ package setting
 
 import (
 	"gitee.com/kelvins-io/common/log"
+	"strings"
 	"time"
 )
 
 // ServerSettingS defines for server.
 type ServerSettingS struct {
-	AppName     string
-	PIDFile     string
-	Environment string
+	AppName             string
+	PIDFile             string
+	Environment         string `validate:"oneof=dev test release prod"`
+	ShutdownDrainSecond int    `validate:"min=0"` // unit second, health is NOT_SERVING and the service is unregistered before stopping
+	ShutdownGraceSecond int    `validate:"min=0"` // unit second, wait for the in-flight work, default 30
+	ShutdownForceSecond int    `validate:"min=0"` // unit second, the process exits after the forced stop, default 5
+	RegisterTTLSecond   int    `validate:"min=0"` // unit second, ttl of the etcd lease of the service registration, default 10
+	AdvertiseAddr       string // ip or host registered for the service, default the ip of AdvertiseInterface or the outbound ip
+	AdvertiseInterface  string // network interface whose ip is registered, eg: eth0, ipv4 preferred
+	// the metadata of the service registration
+	AppVersion  string   // version of the app
+	BuildCommit string   // commit the app is built from
+	Zone        string   // eg: cn-hangzhou-b
+	Region      string   // eg: cn-hangzhou
+	Weight      int      `validate:"min=0"` // weight for the balancers, default 100
+	Tags        []string // eg: gray,canary
+	Labels      []string // key=value pairs, eg: team=mall,owner=kelvins
+}
+
+const (
+	DefaultShutdownGraceSecond = 30
+	DefaultShutdownForceSecond = 5
+	DefaultRegisterTTLSecond   = 10
+	DefaultWeight              = 100
+)
+
+func (s *ServerSettingS) GetShutdownDrain() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return time.Duration(s.ShutdownDrainSecond) * time.Second
+}
+
+func (s *ServerSettingS) GetShutdownGrace() time.Duration {
+	if s == nil || s.ShutdownGraceSecond <= 0 {
+		return DefaultShutdownGraceSecond * time.Second
+	}
+	return time.Duration(s.ShutdownGraceSecond) * time.Second
+}
+
+func (s *ServerSettingS) GetShutdownForce() time.Duration {
+	if s == nil || s.ShutdownForceSecond <= 0 {
+		return DefaultShutdownForceSecond * time.Second
+	}
+	return time.Duration(s.ShutdownForceSecond) * time.Second
+}
+
+func (s *ServerSettingS) GetWeight() int {
+	if s == nil || s.Weight <= 0 {
+		return DefaultWeight
+	}
+	return s.Weight
+}
+
+// GetLabels returns Labels as a map, a label without = has an empty value.
+func (s *ServerSettingS) GetLabels() map[string]string {
+	if s == nil || len(s.Labels) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(s.Labels))
+	for _, label := range s.Labels {
+		kv := strings.SplitN(label, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if key == "" {
+			continue
+		}
+		if len(kv) == 2 {
+			labels[key] = strings.TrimSpace(kv[1])
+		} else {
+			labels[key] = ""
+		}
+	}
+	return labels
+}
+
+func (s *ServerSettingS) GetRegisterTTL() time.Duration {
+	if s == nil || s.RegisterTTLSecond <= 0 {
+		return DefaultRegisterTTLSecond * time.Second
+	}
+	return time.Duration(s.RegisterTTLSecond) * time.Second
 }
 
 func (s *HttpServerSettingS) GetReadTimeout() time.Duration {
@@ -33,83 +111,83 @@ func (s *HttpServerSettingS) GetAddr() string {
 }
 
 type HttpServerSettingS struct {
-	Network      string
-	ReadTimeout  int
-	WriteTimeout int
-	IdleTimeout  int
+	Network      string `validate:"oneof=tcp tcp4 tcp6 unix"`
+	ReadTimeout  int    `validate:"min=0"`
+	WriteTimeout int    `validate:"min=0"`
+	IdleTimeout  int    `validate:"min=0"`
 	SupportH2    bool
 	addr         string
 }
 
 type HttpRateLimitSettingS struct {
-	MaxConcurrent int
+	MaxConcurrent int `validate:"min=0"`
 }
 
 type JwtSettingS struct {
 	Secret            string
-	TokenExpireSecond int
+	TokenExpireSecond int `validate:"min=0"`
 }
 
 type RPCServerParamsS struct {
-	NumServerWorkers             int64
-	ConnectionTimeout            int64 // unit second
+	NumServerWorkers             int64 `validate:"min=0"`
+	ConnectionTimeout            int64 `validate:"min=0"` // unit second
 	DisableClientDialHealthCheck bool
 	DisableHealthServer          bool
 }
 
 type RPCAuthSettingS struct {
 	Token             string
-	ExpireSecond      int
+	ExpireSecond      int `validate:"min=0"`
 	TransportSecurity bool
 }
 
 type RPCRateLimitSettingS struct {
-	MaxConcurrent int
+	MaxConcurrent int `validate:"min=0"`
 }
 
 type RPCServerKeepaliveParamsS struct {
-	PingClientIntervalTime int64
-	MaxConnectionIdle      int64
+	PingClientIntervalTime int64 `validate:"min=0"`
+	MaxConnectionIdle      int64 `validate:"min=0"`
 }
 
 type RPCServerKeepaliveEnforcementPolicyS struct {
-	ClientMinIntervalTime int64
+	ClientMinIntervalTime int64 `validate:"min=0"`
 	PermitWithoutStream   bool
 }
 
 type RPCClientKeepaliveParamsS struct {
-	PingServerIntervalTime int64
+	PingServerIntervalTime int64 `validate:"min=0"`
 	PermitWithoutStream    bool
 }
 
 type RPCTransportBufferS struct {
-	ServerReadBufSizeKB  int
-	ServerWriteBufSizeKB int
-	ClientReadBufSizeKB  int
-	ClientWriteBufSizeKB int
+	ServerReadBufSizeKB  int `validate:"min=0"`
+	ServerWriteBufSizeKB int `validate:"min=0"`
+	ClientReadBufSizeKB  int `validate:"min=0"`
+	ClientWriteBufSizeKB int `validate:"min=0"`
 }
 
 type LoggerSettingS struct {
 	RootPath string
-	Level    string
+	Level    string `validate:"oneof=debug info warn error"`
 }
 
 // MysqlSettingS defines for connecting mysql.
 type MysqlSettingS struct {
 	Host              string
-	UserName          string
-	Password          string
-	DBName            string
-	Charset           string
-	MaxIdle           int
-	MaxOpen           int
+	UserName          string `validate:"required_with=Host"`
+	Password          string `validate:"required_with=Host"`
+	DBName            string `validate:"required_with=Host"`
+	Charset           string `validate:"required_with=Host"`
+	MaxIdle           int    `validate:"min=0"`
+	MaxOpen           int    `validate:"min=0"`
 	Loc               string
-	ConnMaxLifeSecond int
+	ConnMaxLifeSecond int `validate:"min=0"`
 	MultiStatements   bool
 	ParseTime         bool
-	ConnectionTimeout string // time unit eg: 2h 3s
-	WriteTimeout      string // time unit eg: 2h 3s
-	ReadTimeout       string // time unit eg: 2h 3s
+	ConnectionTimeout string `validate:"duration"` // time unit eg: 2h 3s
+	WriteTimeout      string `validate:"duration"` // time unit eg: 2h 3s
+	ReadTimeout       string `validate:"duration"` // time unit eg: 2h 3s
 	// only app use
 	LoggerLevel string
 	Environment string
@@ -119,25 +197,25 @@ type MysqlSettingS struct {
 // RedisSettingS defines for connecting redis.
 type RedisSettingS struct {
 	Host           string
-	Password       string
-	MaxIdle        int
-	MaxActive      int
-	IdleTimeout    int // unit second
-	ConnectTimeout int // unit second
-	ReadTimeout    int // unit second
-	WriteTimeout   int // unit second
-	DB             int
+	Password       string `validate:"required_with=Host"`
+	MaxIdle        int    `validate:"min=0"`
+	MaxActive      int    `validate:"min=0"`
+	IdleTimeout    int    `validate:"min=0"` // unit second
+	ConnectTimeout int    `validate:"min=0"` // unit second
+	ReadTimeout    int    `validate:"min=0"` // unit second
+	WriteTimeout   int    `validate:"min=0"` // unit second
+	DB             int    `validate:"min=0,max=15"`
 }
 
 type G2CacheSettingS struct {
 	CacheDebug             bool
 	CacheMonitor           bool
 	OutCachePubSub         bool
-	CacheMonitorSecond     int
+	CacheMonitorSecond     int `validate:"min=0"`
 	EntryLazyFactor        int
 	GPoolWorkerNum         int
 	GPoolJobQueueChanLen   int
-	FreeCacheSize          int // byte size
+	FreeCacheSize          int `validate:"min=0"` // byte size
 	PubSubRedisChannel     string
 	RedisConfDSN           string
 	RedisConfDB            int
@@ -151,51 +229,51 @@ type G2CacheSettingS struct {
 
 // QueueServerSettingS defines what queue server needs.
 type QueueServerSettingS struct {
-	WorkerConcurrency int
+	WorkerConcurrency int `validate:"min=0"`
 	CustomQueueList   []string
 }
 
 // QueueRedisSettingS defines for redis queue.
 type QueueRedisSettingS struct {
 	Broker           string
-	DefaultQueue     string
-	ResultBackend    string
-	ResultsExpireIn  int
+	DefaultQueue     string `validate:"required_with=Broker"`
+	ResultBackend    string `validate:"required_with=Broker"`
+	ResultsExpireIn  int    `validate:"min=0"`
 	DisableConsume   bool
-	TaskRetryCount   int
-	TaskRetryTimeout int
+	TaskRetryCount   int `validate:"min=0"`
+	TaskRetryTimeout int `validate:"min=0"`
 }
 
 // QueueAliAMQPSettingS defines for ali yun AMQP queue
 type QueueAliAMQPSettingS struct {
-	AccessKey        string
-	SecretKey        string
-	AliUid           int
-	EndPoint         string
+	AccessKey        string `validate:"required_with=VHost"`
+	SecretKey        string `validate:"required_with=VHost"`
+	AliUid           int    `validate:"min=0"`
+	EndPoint         string `validate:"required_with=VHost"`
 	VHost            string
-	DefaultQueue     string
-	ResultBackend    string
-	ResultsExpireIn  int
+	DefaultQueue     string `validate:"required_with=VHost"`
+	ResultBackend    string `validate:"required_with=VHost"`
+	ResultsExpireIn  int    `validate:"min=0"`
 	Exchange         string
-	ExchangeType     string
+	ExchangeType     string `validate:"oneof=direct fanout topic headers"`
 	BindingKey       string
-	PrefetchCount    int
-	TaskRetryCount   int
-	TaskRetryTimeout int
+	PrefetchCount    int `validate:"min=0"`
+	TaskRetryCount   int `validate:"min=0"`
+	TaskRetryTimeout int `validate:"min=0"`
 	DisableConsume   bool
 }
 
 type QueueAMQPSettingS struct {
 	Broker           string
-	DefaultQueue     string
-	ResultBackend    string
-	ResultsExpireIn  int
+	DefaultQueue     string `validate:"required_with=Broker"`
+	ResultBackend    string `validate:"required_with=Broker"`
+	ResultsExpireIn  int    `validate:"min=0"`
 	Exchange         string
-	ExchangeType     string
+	ExchangeType     string `validate:"oneof=direct fanout topic headers"`
 	BindingKey       string
-	PrefetchCount    int
-	TaskRetryCount   int
-	TaskRetryTimeout int
+	PrefetchCount    int `validate:"min=0"`
+	TaskRetryCount   int `validate:"min=0"`
+	TaskRetryTimeout int `validate:"min=0"`
 	DisableConsume   bool
 }
 
@@ -211,15 +289,136 @@ type AliRocketMQSettingS struct {
 
 type MongoDBSettingS struct {
 	Uri         string
-	Username    string
-	Password    string
-	Database    string
-	AuthSource  string
-	MaxPoolSize int
-	MinPoolSize int
+	Username    string `validate:"required_with=Uri"`
+	Password    string `validate:"required_with=Uri"`
+	Database    string `validate:"required_with=Uri"`
+	AuthSource  string `validate:"required_with=Uri"`
+	MaxPoolSize int    `validate:"min=0"`
+	MinPoolSize int    `validate:"min=0"`
 }
 
 type GPoolSettingS struct {
-	WorkerNum  int
-	JobChanLen int
+	WorkerNum  int `validate:"min=0"`
+	JobChanLen int `validate:"min=0"`
+}
+
+// ConfigSettingS defines for config reload.
+type ConfigSettingS struct {
+	WatchDisable        bool   // true means the file is not watched, SIGHUP still reloads
+	WatchIntervalSecond int    `validate:"min=0"` // unit second
+	RemoteEnable        bool   // true means the config published to etcd overrides the config file
+	RemotePrefix        string // etcd key prefix, default /kelvins-config
+}
+
+// ReadinessSettingS defines the dependency checks before serving.
+type ReadinessSettingS struct {
+	Disable             bool   // true means the dependencies are not pinged
+	Policy              string `validate:"oneof=fail retry"` // fail: startup fails after the retries, retry: retry until the dependencies are ready, default fail
+	Retries             int    `validate:"min=0"`            // retries of the fail policy, default 3
+	TimeoutSecond       int    `validate:"min=0"`            // unit second, timeout of one ping, default 3
+	BackoffSecond       int    `validate:"min=0"`            // unit second, backoff of the first retry, doubled every retry, default 1
+	MaxBackoffSecond    int    `validate:"min=0"`            // unit second, default 30
+	CheckIntervalSecond int    `validate:"min=0"`            // unit second, interval of the checks feeding the health server, default 10
+	LivenessPath        string // path of the liveness handler on the http app port, default /healthz, - means not served
+	ReadinessPath       string // path of the readiness handler on the http app port, default /readyz, - means not served
+}
+
+const (
+	ReadinessPolicyFail  = "fail"
+	ReadinessPolicyRetry = "retry"
+)
+
+const (
+	DefaultLivenessPath  = "/healthz"
+	DefaultReadinessPath = "/readyz"
+)
+
+func (s *ReadinessSettingS) GetLivenessPath() string {
+	if s == nil || s.LivenessPath == "" {
+		return DefaultLivenessPath
+	}
+	return s.LivenessPath
 }
+
+func (s *ReadinessSettingS) GetReadinessPath() string {
+	if s == nil || s.ReadinessPath == "" {
+		return DefaultReadinessPath
+	}
+	return s.ReadinessPath
+}
+
+func (s *ReadinessSettingS) GetPolicy() string {
+	if s == nil || s.Policy == "" {
+		return ReadinessPolicyFail
+	}
+	return s.Policy
+}
+
+func (s *ReadinessSettingS) GetRetries() int {
+	if s == nil || s.Retries <= 0 {
+		return 3
+	}
+	return s.Retries
+}
+
+func (s *ReadinessSettingS) GetTimeout() time.Duration {
+	if s == nil || s.TimeoutSecond <= 0 {
+		return 3 * time.Second
+	}
+	return time.Duration(s.TimeoutSecond) * time.Second
+}
+
+func (s *ReadinessSettingS) GetBackoff() time.Duration {
+	if s == nil || s.BackoffSecond <= 0 {
+		return time.Second
+	}
+	return time.Duration(s.BackoffSecond) * time.Second
+}
+
+func (s *ReadinessSettingS) GetMaxBackoff() time.Duration {
+	if s == nil || s.MaxBackoffSecond <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(s.MaxBackoffSecond) * time.Second
+}
+
+func (s *ReadinessSettingS) GetCheckInterval() time.Duration {
+	if s == nil || s.CheckIntervalSecond <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(s.CheckIntervalSecond) * time.Second
+}
+
+// AdminSettingS defines the admin http server serving metrics, health, config dump and logger level.
+type AdminSettingS struct {
+	Host              string // listen host, default 127.0.0.1
+	Port              int64  `validate:"min=0,max=65535"` // 0 means the admin server is not started
+	PProfEnable       bool   // serve /debug/pprof
+	BasicAuthUser     string // not empty means basic auth, /healthz /readyz are not authed
+	BasicAuthPassword string
+}
+
+func (s *AdminSettingS) Enabled() bool {
+	return s != nil && s.Port > 0
+}
+
+func (s *AdminSettingS) GetHost() string {
+	if s == nil || s.Host == "" {
+		return "127.0.0.1"
+	}
+	return s.Host
+}
+
+// RegistrySettingS defines the service registry.
+type RegistrySettingS struct {
+	Type                string `validate:"oneof=etcd file memory"` // default etcd, file and memory are for local development
+	FilePath            string // json file of the file registry, default ./etc/registry.json
+	WatchIntervalSecond int    `validate:"min=0"` // unit second, interval the file registry checks the file, default 3
+	SnapshotPath        string // snapshot of the discovered instances of the etcd registry for warm start, empty to disable
+}
+
+const (
+	RegistryTypeEtcd   = "etcd"
+	RegistryTypeFile   = "file"
+	RegistryTypeMemory = "memory"
+)
@@ -5,6 +5,7 @@ go 1.13
 require (
 	gitee.com/kelvins-io/common v1.1.5
 	gitee.com/kelvins-io/g2cache v4.0.5+incompatible
+	github.com/BurntSushi/toml v0.3.1
 	github.com/RichardKnop/machinery v1.9.1
 	github.com/StackExchange/wmi v0.0.0-20190523213315-cbe66965904d // indirect
 	github.com/aliyun/alibaba-cloud-sdk-go v1.61.685 // indirect
@@ -14,7 +15,6 @@ require (
 	github.com/bojand/ghz v0.100.0
 	github.com/cloudflare/tableflip v1.2.2
 	github.com/coocood/freecache v1.1.1 // indirect
-	github.com/etcd-io/etcd v3.3.25+incompatible
 	github.com/gin-contrib/pprof v1.3.0
 	github.com/gin-gonic/gin v1.7.1
 	github.com/go-ole/go-ole v1.2.4 // indirect
@@ -29,15 +29,17 @@ require (
 	github.com/grpc-ecosystem/grpc-gateway v1.16.0
 	github.com/jinzhu/gorm v1.9.15
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
-	github.com/prometheus/client_golang v1.7.0
+	github.com/prometheus/client_golang v1.11.0
 	github.com/qiniu/qmgo v0.7.0
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/rs/zerolog v1.20.0 // indirect
 	github.com/shirou/gopsutil v3.20.10+incompatible // indirect
 	github.com/valyala/fasthttp v1.17.0 // indirect
 	go.elastic.co/apm v1.9.0 // indirect
-	golang.org/x/net v0.0.0-20201021035429-f5854403a974
+	go.etcd.io/etcd/client/v3 v3.5.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 	google.golang.org/grpc v1.40.0
 	gopkg.in/ini.v1 v1.51.0
+	gopkg.in/yaml.v2 v2.3.0
 	xorm.io/xorm v1.0.3
 )
@@ -0,0 +1,79 @@
+package kelvins
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HookPhase is a stage of the application lifecycle.
+type HookPhase int32
+
+const (
+	HookBeforeInit     HookPhase = 1 // before the config is loaded
+	HookAfterSetupVars HookPhase = 2 // after Application.SetupVars, the framework vars are ready
+	HookBeforeServe    HookPhase = 3 // before the servers listen
+	HookAfterServe     HookPhase = 4 // after the servers are started
+	HookBeforeShutdown HookPhase = 5 // before the servers are stopped
+	HookAfterShutdown  HookPhase = 6 // after the framework resources are closed
+)
+
+var (
+	HookPhaseText = map[HookPhase]string{
+		HookBeforeInit:     "BeforeInit",
+		HookAfterSetupVars: "AfterSetupVars",
+		HookBeforeServe:    "BeforeServe",
+		HookAfterServe:     "AfterServe",
+		HookBeforeShutdown: "BeforeShutdown",
+		HookAfterShutdown:  "AfterShutdown",
+	}
+)
+
+// DefaultHookTimeout is the deadline of a hook without Timeout.
+const DefaultHookTimeout = 10 * time.Second
+
+// Hook is run by the framework at Phase.
+// a failed startup hook stops the application, the shutdown hooks always run all.
+type Hook struct {
+	Name     string
+	Phase    HookPhase
+	Priority int           // lower runs first, hooks of the same priority run in register order
+	Timeout  time.Duration // deadline of the ctx passed to Func, DefaultHookTimeout if 0
+	Func     func(ctx context.Context) error
+}
+
+var (
+	hooksMutex sync.Mutex
+	hooks      []Hook
+)
+
+// RegisterHook registers hook, it should be called before Run*Application.
+func RegisterHook(hook Hook) {
+	if hook.Func == nil {
+		panic(fmt.Sprintf("hook %q Func is nil", hook.Name))
+	}
+	if _, ok := HookPhaseText[hook.Phase]; !ok {
+		panic(fmt.Sprintf("hook %q phase %d is invalid", hook.Name, hook.Phase))
+	}
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// Hooks returns the hooks of phase in run order.
+func Hooks(phase HookPhase) []Hook {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	var result []Hook
+	for _, hook := range hooks {
+		if hook.Phase == phase {
+			result = append(result, hook)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Priority < result[j].Priority
+	})
+	return result
+}
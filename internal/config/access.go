@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSectionNotExist is returned by MapConfigE when the section is absent from every config layer.
+var ErrSectionNotExist = errors.New("section does not exist")
+
+// MapConfigE is MapConfig which returns the problems as an error instead of exiting the process,
+// so optional sections can be skipped. v is not modified by a reload, see MapConfig.
+func MapConfigE(section string, v interface{}) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if provider == nil {
+		return fmt.Errorf("config is not loaded")
+	}
+	if !sectionPresent(section, v) {
+		return fmt.Errorf("section %q: %w", section, ErrSectionNotExist)
+	}
+	sectionProblems := mapSection(section, v)
+	if len(sectionProblems) > 0 {
+		return &ValidationError{Problems: sectionProblems}
+	}
+	userSections[section] = v
+	settings.Store(section, v)
+	return nil
+}
+
+// Exists reports whether section is set by the config file, a profile, the remote config or a -set flag.
+func Exists(section string) bool {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	return provider != nil && provider.HasSection(section)
+}
+
+// Sections returns the sorted names of the sections set by the config file, the profiles, the remote config and -set flags.
+func Sections() []string {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if provider == nil {
+		return nil
+	}
+	sections := provider.Sections()
+	sort.Strings(sections)
+	return sections
+}
+
+// Get returns the effective value of section.key, resolved as: defaults < files < env vars < -set flags.
+// secrets are decrypted, list values are joined by comma.
+func Get(section, key string) (string, bool) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	value, source, ok := lookupValue(section, key)
+	if !ok {
+		return "", false
+	}
+	plain, _, err := resolveSecret(section, key, value, source)
+	if err != nil {
+		log.Printf("[err] Config %s.%s secret err: %v", section, key, err)
+		return "", false
+	}
+	return plain, true
+}
+
+func lookupValue(section, key string) (string, Source, bool) {
+	if value, ok := lookupFlag(section, key); ok {
+		return value, SourceFlag, true
+	}
+	if value, ok := os.LookupEnv(EnvName(section, key)); ok {
+		return value, SourceEnv, true
+	}
+	if provider != nil {
+		if value, ok := provider.Value(section, key); ok {
+			if isRemoteKey(section, key) {
+				return stringValue(value), SourceRemote, true
+			}
+			return stringValue(value), SourceFile, true
+		}
+	}
+	value, ok := lookupDefault(section, key)
+	return value, SourceDefault, ok
+}
+
+// GetString returns the value of section.key, or def when the key is not set.
+func GetString(section, key, def string) string {
+	if value, ok := Get(section, key); ok {
+		return value
+	}
+	return def
+}
+
+// GetInt returns the value of section.key as int, or def when the key is not set or invalid.
+func GetInt(section, key string, def int) int {
+	value, ok := Get(section, key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		log.Printf("[err] Config %s.%s=%q is not int, use default %v", section, key, value, def)
+		return def
+	}
+	return n
+}
+
+// GetBool returns the value of section.key as bool, or def when the key is not set or invalid.
+func GetBool(section, key string, def bool) bool {
+	value, ok := Get(section, key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		log.Printf("[err] Config %s.%s=%q is not bool, use default %v", section, key, value, def)
+		return def
+	}
+	return b
+}
+
+// GetDuration returns the value of section.key parsed by time.ParseDuration eg: 3s 2h,
+// or def when the key is not set or invalid.
+func GetDuration(section, key string, def time.Duration) time.Duration {
+	value, ok := Get(section, key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		log.Printf("[err] Config %s.%s=%q is not duration, use default %v", section, key, value, def)
+		return def
+	}
+	return d
+}
+
+// GetStringSlice returns the comma separated value of section.key, or def when the key is not set.
+func GetStringSlice(section, key string, def []string) []string {
+	value, ok := Get(section, key)
+	if !ok {
+		return def
+	}
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
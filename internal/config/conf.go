@@ -2,10 +2,13 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"gitee.com/kelvins-io/kelvins"
-	"gitee.com/kelvins-io/kelvins/config/setting"
-	"gopkg.in/ini.v1"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 )
 
 const (
@@ -57,152 +60,149 @@ const (
 	SectionRPCTransportBuffer = "kelvins-rpc-transport-buffer"
 	// SectionRPCRateLimit is rpc rate limit
 	SectionRPCRateLimit = "kelvins-rpc-rate-limit"
+	// SectionConfig is config watch
+	SectionConfig = "kelvins-config"
+	// SectionReadiness is dependency readiness checks
+	SectionReadiness = "kelvins-readiness"
+	// SectionAdmin is admin http server
+	SectionAdmin = "kelvins-admin"
+	// SectionRegistry is service registry
+	SectionRegistry = "kelvins-registry"
 )
 
-// cfg reads file app.ini.
+// provider holds the parsed config file.
 var (
-	cfg            *ini.File
-	flagConfigPath = flag.String("conf_file", "", "set config file path")
+	provider       Provider
+	flagConfigPath = flag.String("conf_file", "", "set config file path, the format is picked by extension eg: .ini .yaml .toml .json")
 )
 
-// LoadDefaultConfig loads config form cfg.
+// defaultSections binds every framework section to its kelvins.*Setting global.
+var defaultSections = []struct {
+	name   string
+	target interface{}
+}{
+	{SectionServer, &kelvins.ServerSetting},
+	{SectionHttpServer, &kelvins.HttpServerSetting},
+	{SectionHttpRateLimit, &kelvins.HttpRateLimitSetting},
+	{SectionJwt, &kelvins.JwtSetting},
+	{SectionAuth, &kelvins.RPCAuthSetting},
+	{SectionRPCAuth, &kelvins.RPCAuthSetting},
+	{SectionRPCServerParams, &kelvins.RPCServerParamsSetting},
+	{SectionRPCServerKeepaliveParams, &kelvins.RPCServerKeepaliveParamsSetting},
+	{SectionRPCServerKeepaliveEnforcementPolicy, &kelvins.RPCServerKeepaliveEnforcementPolicySetting},
+	{SectionRPCClientKeepaliveParams, &kelvins.RPCClientKeepaliveParamsSetting},
+	{SectionRPCTransportBuffer, &kelvins.RPCTransportBufferSetting},
+	{SectionRPCRateLimit, &kelvins.RPCRateLimitSetting},
+	{SectionLogger, &kelvins.LoggerSetting},
+	{SectionMysql, &kelvins.MysqlSetting},
+	{SectionRedis, &kelvins.RedisSetting},
+	{SectionG2cache, &kelvins.G2CacheSetting},
+	{SectionMongoDB, &kelvins.MongoDBSetting},
+	{SectionQueueRedis, &kelvins.QueueRedisSetting},
+	{SectionQueueAliAMQP, &kelvins.QueueAliAMQPSetting},
+	{SectionQueueAMQP, &kelvins.QueueAMQPSetting},
+	{SectionQueueAliRocketMQ, &kelvins.AliRocketMQSetting},
+	{SectionQueueServer, &kelvins.QueueServerSetting},
+	{SectionGPool, &kelvins.GPoolSetting},
+	{SectionConfig, &kelvins.ConfigSetting},
+	{SectionReadiness, &kelvins.ReadinessSetting},
+	{SectionAdmin, &kelvins.AdminSetting},
+	{SectionRegistry, &kelvins.RegistrySetting},
+}
+
+// LoadDefaultConfig loads config form provider.
+// the effective value of a key is resolved as: defaults < config file < profiles < remote config < env vars < -set flags.
 func LoadDefaultConfig(application *kelvins.Application) error {
 	flag.Parse()
-	var configFile = ConfFileName
+	var configFile = lookupConfigFile()
 	if *flagConfigPath != "" {
 		configFile = *flagConfigPath
 	}
 
-	// Setup cfg object
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	// Setup provider object
 	var err error
-	cfg, err = ini.Load(configFile)
+	provider, loadedFiles, err = parseProfiles(configFile)
+	if err != nil {
+		return err
+	}
+	resetOrigins()
+	// -set flags may introduce sections which are absent from the file
+	applyFlagSections(provider)
+	err = setupRemote(application)
 	if err != nil {
 		return err
 	}
+	if remoteRelease != nil {
+		err = mergeRemote(provider, remoteRelease)
+		if err != nil {
+			return err
+		}
+		applyFlagSections(provider)
+	}
+	snapshots = fingerprints(provider)
 
 	// Setup default settings
-	for _, sectionName := range cfg.SectionStrings() {
-		if sectionName == SectionServer {
-			kelvins.ServerSetting = new(setting.ServerSettingS)
-			MapConfig(sectionName, kelvins.ServerSetting)
-			continue
-		}
-		if sectionName == SectionHttpServer {
-			kelvins.HttpServerSetting = new(setting.HttpServerSettingS)
-			MapConfig(sectionName, kelvins.HttpServerSetting)
-		}
-		if sectionName == SectionHttpRateLimit {
-			kelvins.HttpRateLimitSetting = new(setting.HttpRateLimitSettingS)
-			MapConfig(sectionName, kelvins.HttpRateLimitSetting)
-		}
-		if sectionName == SectionJwt {
-			kelvins.JwtSetting = new(setting.JwtSettingS)
-			MapConfig(sectionName, kelvins.JwtSetting)
-			continue
-		}
-		if sectionName == SectionRPCAuth || sectionName == SectionAuth {
-			kelvins.RPCAuthSetting = new(setting.RPCAuthSettingS)
-			MapConfig(sectionName, kelvins.RPCAuthSetting)
-			continue
-		}
-		if sectionName == SectionRPCServerParams {
-			kelvins.RPCServerParamsSetting = new(setting.RPCServerParamsS)
-			MapConfig(sectionName, kelvins.RPCServerParamsSetting)
-			continue
-		}
-		if sectionName == SectionRPCServerKeepaliveParams {
-			kelvins.RPCServerKeepaliveParamsSetting = new(setting.RPCServerKeepaliveParamsS)
-			MapConfig(sectionName, kelvins.RPCServerKeepaliveParamsSetting)
-			continue
-		}
-		if sectionName == SectionRPCServerKeepaliveEnforcementPolicy {
-			kelvins.RPCServerKeepaliveEnforcementPolicySetting = new(setting.RPCServerKeepaliveEnforcementPolicyS)
-			MapConfig(sectionName, kelvins.RPCServerKeepaliveEnforcementPolicySetting)
-			continue
-		}
-		if sectionName == SectionRPCClientKeepaliveParams {
-			kelvins.RPCClientKeepaliveParamsSetting = new(setting.RPCClientKeepaliveParamsS)
-			MapConfig(sectionName, kelvins.RPCClientKeepaliveParamsSetting)
-			continue
-		}
-		if sectionName == SectionRPCTransportBuffer {
-			kelvins.RPCTransportBufferSetting = new(setting.RPCTransportBufferS)
-			MapConfig(sectionName, kelvins.RPCTransportBufferSetting)
-			continue
-		}
-		if sectionName == SectionRPCRateLimit {
-			kelvins.RPCRateLimitSetting = new(setting.RPCRateLimitSettingS)
-			MapConfig(sectionName, kelvins.RPCRateLimitSetting)
-			continue
-		}
-		if sectionName == SectionLogger {
-			kelvins.LoggerSetting = new(setting.LoggerSettingS)
-			MapConfig(sectionName, kelvins.LoggerSetting)
-			continue
-		}
-		if sectionName == SectionMysql {
-			kelvins.MysqlSetting = new(setting.MysqlSettingS)
-			MapConfig(sectionName, kelvins.MysqlSetting)
-			continue
-		}
-		if sectionName == SectionRedis {
-			kelvins.RedisSetting = new(setting.RedisSettingS)
-			MapConfig(sectionName, kelvins.RedisSetting)
-			continue
-		}
-		if sectionName == SectionG2cache {
-			kelvins.G2CacheSetting = new(setting.G2CacheSettingS)
-			MapConfig(sectionName, kelvins.G2CacheSetting)
-			continue
-		}
-		if sectionName == SectionMongoDB {
-			kelvins.MongoDBSetting = new(setting.MongoDBSettingS)
-			MapConfig(sectionName, kelvins.MongoDBSetting)
-			continue
-		}
-		if sectionName == SectionQueueRedis {
-			kelvins.QueueRedisSetting = new(setting.QueueRedisSettingS)
-			MapConfig(sectionName, kelvins.QueueRedisSetting)
-			continue
-		}
-		if sectionName == SectionQueueAliAMQP {
-			kelvins.QueueAliAMQPSetting = new(setting.QueueAliAMQPSettingS)
-			MapConfig(sectionName, kelvins.QueueAliAMQPSetting)
-			continue
-		}
-		if sectionName == SectionQueueAMQP {
-			kelvins.QueueAMQPSetting = new(setting.QueueAMQPSettingS)
-			MapConfig(sectionName, kelvins.QueueAMQPSetting)
-			continue
-		}
-		if sectionName == SectionQueueAliRocketMQ {
-			kelvins.AliRocketMQSetting = new(setting.AliRocketMQSettingS)
-			MapConfig(sectionName, kelvins.AliRocketMQSetting)
-			continue
-		}
-		if sectionName == SectionQueueServer {
-			kelvins.QueueServerSetting = new(setting.QueueServerSettingS)
-			MapConfig(sectionName, kelvins.QueueServerSetting)
-			continue
-		}
-		if sectionName == SectionGPool {
-			kelvins.GPoolSetting = new(setting.GPoolSettingS)
-			MapConfig(sectionName, kelvins.GPoolSetting)
+	for _, sec := range defaultSections {
+		// target is a pointer to a kelvins.*Setting pointer
+		target := reflect.ValueOf(sec.target).Elem()
+		v := reflect.New(target.Type().Elem())
+		if !sectionPresent(sec.name, v.Interface()) {
 			continue
 		}
+		// problems are reported together by Check
+		problems = append(problems, mapSection(sec.name, v.Interface())...)
+		target.Set(v)
+		settings.Store(sec.name, v.Interface())
 	}
 	return nil
 }
 
-// MapConfig uses cfg to map config.
+// lookupConfigFile falls back to ./etc/app.{yaml,yml,toml,json} when ./etc/app.ini does not exist.
+func lookupConfigFile() string {
+	if _, err := os.Stat(ConfFileName); err == nil {
+		return ConfFileName
+	}
+	base := strings.TrimSuffix(ConfFileName, filepath.Ext(ConfFileName))
+	for _, ext := range []string{".yaml", ".yml", ".toml", ".json"} {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return ConfFileName
+}
+
+// MapConfig uses provider to map config, then validates v by the validate struct tags.
+// v is not modified by a reload, the reloaded values are passed to the OnChange handlers and returned by Setting.
+// problems found during startup are reported together by Check, later ones exit the process.
 func MapConfig(section string, v interface{}) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	sectionProblems := mapSection(section, v)
+	userSections[section] = v
+	settings.Store(section, v)
+	if !checked {
+		problems = append(problems, sectionProblems...)
+		return
+	}
+	if len(sectionProblems) > 0 {
+		log.Fatalf("[err] %v", &ValidationError{Problems: sectionProblems})
+	}
+}
+
+// mapSection maps section onto v and returns the problems found.
+func mapSection(section string, v interface{}) []string {
 	log.Printf("[info] Load default config %s", section)
-	sec, err := cfg.GetSection(section)
-	if err != nil {
-		log.Fatalf("[err] Fail to parse '%s': %v", section, err)
+	if !provider.HasSection(section) && !envSectionPresent(section, v) {
+		return []string{fmt.Sprintf("section %q does not exist", section)}
 	}
-	err = sec.MapTo(v)
+	sources := map[string]Source{}
+	secretProblems := overlaySection(provider, section, v, sources)
+	setOrigins(section, sources)
+	err := provider.MapTo(section, v)
 	if err != nil {
-		log.Fatalf("[err] %s section map to setting err: %v", section, err)
+		return append(secretProblems, fmt.Sprintf("section %q map to setting err: %v", section, err))
 	}
+	return append(secretProblems, validateStruct(section, v)...)
 }
@@ -1,9 +1,25 @@
 package config
 
 import (
+	"flag"
 	"os"
 )
 
+var flagEnv = flag.String("env", "", "set exec environment eg: dev,test,prod")
+
+// FlagEnvironment returns the environment set by flag -env.
+func FlagEnvironment() string {
+	return *flagEnv
+}
+
+// SelectedEnvironment returns the environment selected by flag -env, then env GO_ENV, empty when neither is set.
+func SelectedEnvironment() string {
+	if FlagEnvironment() != "" {
+		return FlagEnvironment()
+	}
+	return os.Getenv(GO_ENV)
+}
+
 const (
 	// ETCD V3 Server URL
 	ENV_ETCDV3_SERVER_URL = "ETCDV3_SERVER_URL"
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Source describes where the effective value of a config key came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceRemote  Source = "remote"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// setFlag collects repeatable -set section.key=value overrides.
+type setFlag []string
+
+func (s *setFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlag) Set(value string) error {
+	if _, _, _, err := parseSetValue(value); err != nil {
+		return err
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+var flagSetValues setFlag
+
+func init() {
+	flag.Var(&flagSetValues, "set", "override config key, can be repeated eg: -set kelvins-redis.Host=127.0.0.1:6379")
+}
+
+// parseSetValue splits section.key=value, the section may contain dots so the last one is used.
+func parseSetValue(s string) (section, key, value string, err error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", "", "", fmt.Errorf("set value %q format should be section.key=value", s)
+	}
+	path := s[:i]
+	j := strings.LastIndex(path, ".")
+	if j <= 0 || j == len(path)-1 {
+		return "", "", "", fmt.Errorf("set value %q format should be section.key=value", s)
+	}
+	return path[:j], path[j+1:], s[i+1:], nil
+}
+
+var (
+	defaultsMutex sync.RWMutex
+	defaults      = map[string]map[string]string{
+		SectionLogger: {
+			"RootPath": "./logs",
+			"Level":    "info",
+		},
+		SectionHttpServer: {
+			"Network": "tcp",
+		},
+	}
+)
+
+// SetDefault registers the default value of section.key, it is used when no other layer sets the key.
+// call it before the section is mapped.
+func SetDefault(section, key, value string) {
+	defaultsMutex.Lock()
+	defer defaultsMutex.Unlock()
+	if defaults[section] == nil {
+		defaults[section] = map[string]string{}
+	}
+	defaults[section][key] = value
+}
+
+func lookupDefault(section, key string) (string, bool) {
+	defaultsMutex.RLock()
+	defer defaultsMutex.RUnlock()
+	for k, v := range defaults[section] {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func lookupFlag(section, key string) (string, bool) {
+	var (
+		value string
+		ok    bool
+	)
+	// the last flag wins
+	for _, s := range flagSetValues {
+		sec, k, v, err := parseSetValue(s)
+		if err != nil {
+			continue
+		}
+		if sec == section && strings.EqualFold(k, key) {
+			value, ok = v, true
+		}
+	}
+	return value, ok
+}
+
+// applyFlagSections writes -set flags into p, so sections only referenced by flags exist.
+func applyFlagSections(p Provider) {
+	for _, s := range flagSetValues {
+		sec, key, value, err := parseSetValue(s)
+		if err != nil {
+			continue
+		}
+		p.Set(sec, key, value)
+	}
+}
+
+// EnvName returns the env var name which overrides section.key eg: kelvins-mysql.Password => KELVINS_MYSQL_PASSWORD
+func EnvName(section, key string) string {
+	name := strings.ToUpper(section + "_" + key)
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// settingKeys returns the config keys a setting struct maps, following the ini field naming.
+func settingKeys(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Anonymous {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("ini"), ",")[0]; tag != "" {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+func envSectionPresent(section string, v interface{}) bool {
+	for _, key := range settingKeys(v) {
+		if _, ok := os.LookupEnv(EnvName(section, key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sectionPresent(section string, v interface{}) bool {
+	return provider.HasSection(section) || envSectionPresent(section, v)
+}
+
+// overlaySection applies defaults, env vars and -set flags on top of the file values of section in p,
+// then resolves the secrets, the problems of secrets are returned.
+// the source of every key is recorded in sources, it is published by setOrigins when the section is applied.
+func overlaySection(p Provider, section string, v interface{}, sources map[string]Source) []string {
+	var result []string
+	for _, key := range settingKeys(v) {
+		if _, ok := p.Value(section, key); ok {
+			if isRemoteKey(section, key) {
+				sources[key] = SourceRemote
+			} else {
+				sources[key] = SourceFile
+			}
+		} else if value, ok := lookupDefault(section, key); ok {
+			p.Set(section, key, value)
+			sources[key] = SourceDefault
+		}
+		if value, ok := os.LookupEnv(EnvName(section, key)); ok {
+			p.Set(section, key, value)
+			sources[key] = SourceEnv
+			log.Printf("[info] Config %s.%s is overridden by env %s", section, key, EnvName(section, key))
+		}
+		if value, ok := lookupFlag(section, key); ok {
+			p.Set(section, key, value)
+			sources[key] = SourceFlag
+			log.Printf("[info] Config %s.%s is overridden by flag -set", section, key)
+		}
+		value, _ := p.Value(section, key)
+		if s, ok := value.(string); ok {
+			plain, resolved, err := resolveSecret(section, key, s, sources[key])
+			if err != nil {
+				result = append(result, fmt.Sprintf("%s.%s secret err: %v", section, key, err))
+			} else if resolved {
+				p.Set(section, key, plain)
+			}
+		}
+	}
+	return result
+}
+
+var (
+	originsMutex sync.RWMutex
+	origins      = map[string]map[string]Source{}
+)
+
+func resetOrigins() {
+	originsMutex.Lock()
+	origins = map[string]map[string]Source{}
+	originsMutex.Unlock()
+}
+
+// setOrigins records the sources of the keys of an applied section.
+func setOrigins(section string, sources map[string]Source) {
+	originsMutex.Lock()
+	defer originsMutex.Unlock()
+	if origins[section] == nil {
+		origins[section] = map[string]Source{}
+	}
+	for key, source := range sources {
+		origins[section][key] = source
+	}
+}
+
+// Origin reports where the effective value of section.key came from, empty means the key is not set.
+func Origin(section, key string) Source {
+	originsMutex.RLock()
+	defer originsMutex.RUnlock()
+	for k, source := range origins[section] {
+		if strings.EqualFold(k, key) {
+			return source
+		}
+	}
+	return ""
+}
+
+// Origins reports the source of every mapped key, grouped by section.
+func Origins() map[string]map[string]Source {
+	originsMutex.RLock()
+	defer originsMutex.RUnlock()
+	result := make(map[string]map[string]Source, len(origins))
+	for section, keys := range origins {
+		result[section] = make(map[string]Source, len(keys))
+		for key, source := range keys {
+			result[section][key] = source
+		}
+	}
+	return result
+}
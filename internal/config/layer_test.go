@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	cases := []struct {
+		section, key, expect string
+	}{
+		{"kelvins-mysql", "Password", "KELVINS_MYSQL_PASSWORD"},
+		{"kelvins-http-rate-limit", "MaxConcurrent", "KELVINS_HTTP_RATE_LIMIT_MAXCONCURRENT"},
+		{"app.order", "max_idle", "APP_ORDER_MAX_IDLE"},
+		{"kelvins-g2cache", "RedisConfDB", "KELVINS_G2CACHE_REDISCONFDB"},
+	}
+	for _, c := range cases {
+		if got := EnvName(c.section, c.key); got != c.expect {
+			t.Errorf("EnvName(%v, %v) = %v, expect %v", c.section, c.key, got, c.expect)
+		}
+	}
+}
+
+func TestParseSetValue(t *testing.T) {
+	cases := []struct {
+		s                   string
+		section, key, value string
+		err                 bool
+	}{
+		{"kelvins-redis.Host=127.0.0.1:6379", "kelvins-redis", "Host", "127.0.0.1:6379", false},
+		{"app.order.Dsn=user:pwd@tcp(db)/order?a=b", "app.order", "Dsn", "user:pwd@tcp(db)/order?a=b", false},
+		{"kelvins-server.Tags=", "kelvins-server", "Tags", "", false},
+		{"kelvins-redis.Host", "", "", "", true},
+		{"Host=127.0.0.1", "", "", "", true},
+		{"kelvins-redis.=1", "", "", "", true},
+		{"=1", "", "", "", true},
+	}
+	for _, c := range cases {
+		section, key, value, err := parseSetValue(c.s)
+		if (err != nil) != c.err || section != c.section || key != c.key || value != c.value {
+			t.Errorf("parseSetValue(%q) = %q, %q, %q, %v, expect %q, %q, %q, err %v",
+				c.s, section, key, value, err, c.section, c.key, c.value, c.err)
+		}
+	}
+}
+
+type layerSetting struct {
+	Host string
+}
+
+func TestMapConfig_LayerPrecedence(t *testing.T) {
+	const section = "app-layer"
+	env := EnvName(section, "Host")
+	cases := []struct {
+		name           string
+		def, file, env string
+		flags          []string
+		expect         string
+		origin         Source
+		notExist       bool
+	}{
+		{name: "not set", notExist: true},
+		{name: "default", def: "d", expect: "d", origin: SourceDefault, notExist: true},
+		{name: "file over default", def: "d", file: "f", expect: "f", origin: SourceFile},
+		{name: "env over file", def: "d", file: "f", env: "e", expect: "e", origin: SourceEnv},
+		{name: "env without the section in file", env: "e", expect: "e", origin: SourceEnv},
+		{name: "flag over env", def: "d", file: "f", env: "e", flags: []string{section + ".Host=s"}, expect: "s", origin: SourceFlag},
+		{name: "flag over file", file: "f", flags: []string{section + ".host=s"}, expect: "s", origin: SourceFlag},
+		{name: "last flag wins", flags: []string{section + ".Host=s1", section + ".Host=s2"}, expect: "s2", origin: SourceFlag},
+	}
+
+	prevEnv, hasEnv := os.LookupEnv(env)
+	prevFlags := flagSetValues
+	defer func() {
+		if hasEnv {
+			os.Setenv(env, prevEnv)
+		} else {
+			os.Unsetenv(env)
+		}
+		flagSetValues = prevFlags
+		configMutex.Lock()
+		provider = nil
+		configMutex.Unlock()
+		defaultsMutex.Lock()
+		delete(defaults, section)
+		defaultsMutex.Unlock()
+		settings.Delete(section)
+		resetOrigins()
+	}()
+
+	for _, c := range cases {
+		resetOrigins()
+		defaultsMutex.Lock()
+		delete(defaults, section)
+		defaultsMutex.Unlock()
+		if c.def != "" {
+			SetDefault(section, "Host", c.def)
+		}
+		os.Unsetenv(env)
+		if c.env != "" {
+			os.Setenv(env, c.env)
+		}
+		flagSetValues = setFlag(c.flags)
+		content := ""
+		if c.file != "" {
+			content = "[" + section + "]\nHost = \"" + c.file + "\"\n"
+		}
+		p, err := Parse(".ini", []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		applyFlagSections(p)
+		configMutex.Lock()
+		provider = p
+		configMutex.Unlock()
+
+		v := new(layerSetting)
+		err = MapConfigE(section, v)
+		if c.notExist {
+			if err == nil {
+				t.Errorf("%s: expect ErrSectionNotExist", c.name)
+			}
+			// Get still resolves the defaults of an absent section
+			if got, _ := Get(section, "Host"); got != c.def {
+				t.Errorf("%s: Get = %q, expect %q", c.name, got, c.def)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: MapConfigE err: %v", c.name, err)
+		}
+		if v.Host != c.expect {
+			t.Errorf("%s: Host = %q, expect %q", c.name, v.Host, c.expect)
+		}
+		if got, _ := Get(section, "Host"); got != c.expect {
+			t.Errorf("%s: Get = %q, expect %q", c.name, got, c.expect)
+		}
+		if got := Origin(section, "host"); got != c.origin {
+			t.Errorf("%s: Origin = %q, expect %q", c.name, got, c.origin)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitee.com/kelvins-io/kelvins/config/setting"
+)
+
+// profileLocal is the last profile, it is meant for uncommitted settings of one machine.
+const profileLocal = "local"
+
+// profileFiles returns the profiles of base in merge order eg: etc/app.dev.ini etc/app.local.ini
+func profileFiles(base, environment string) []string {
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	var files []string
+	if environment != "" && environment != profileLocal {
+		files = append(files, name+"."+environment+ext)
+	}
+	return append(files, name+"."+profileLocal+ext)
+}
+
+// parseProfiles parses base and merges the existing profiles of the selected environment over it,
+// the environment falls back to kelvins-server.Environment of base.
+// the returned files are watched by Watch, including the profiles which do not exist yet.
+func parseProfiles(base string) (Provider, []string, error) {
+	p, err := ParseFile(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	environment := SelectedEnvironment()
+	if environment == "" && p.HasSection(SectionServer) {
+		serverSetting := new(setting.ServerSettingS)
+		if err := p.MapTo(SectionServer, serverSetting); err == nil {
+			environment = serverSetting.Environment
+		}
+	}
+
+	files := []string{base}
+	for _, filename := range profileFiles(base, environment) {
+		files = append(files, filename)
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+		profile, err := ParseFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		mergeProvider(p, profile)
+		log.Printf("[info] Config profile %s merged", filename)
+	}
+	return p, files, nil
+}
+
+// mergeProvider overrides the keys of dst with the keys of src.
+func mergeProvider(dst, src Provider) {
+	for _, section := range src.Sections() {
+		for _, key := range src.Keys(section) {
+			value, _ := src.Value(section, key)
+			dst.Set(section, key, value)
+		}
+	}
+}
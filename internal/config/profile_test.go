@@ -0,0 +1,82 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfileFiles(t *testing.T) {
+	cases := []struct {
+		base, environment string
+		expect            []string
+	}{
+		{"etc/app.ini", "dev", []string{"etc/app.dev.ini", "etc/app.local.ini"}},
+		{"etc/app.yaml", "prod", []string{"etc/app.prod.yaml", "etc/app.local.yaml"}},
+		{"etc/app.ini", "", []string{"etc/app.local.ini"}},
+		{"etc/app.ini", "local", []string{"etc/app.local.ini"}},
+	}
+	for _, c := range cases {
+		if got := profileFiles(c.base, c.environment); !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("profileFiles(%v, %v) = %v, expect %v", c.base, c.environment, got, c.expect)
+		}
+	}
+}
+
+func TestParseProfiles_MergeOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kelvins-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"app.ini":       "[kelvins-server]\nEnvironment = \"dev\"\n[kelvins-mysql]\nHost = \"base\"\nUserName = \"base\"\nCharset = \"base\"\n",
+		"app.dev.ini":   "[kelvins-mysql]\nHost = \"dev\"\nUserName = \"dev\"\n",
+		"app.prod.ini":  "[kelvins-mysql]\nHost = \"prod\"\n",
+		"app.local.ini": "[kelvins-mysql]\nHost = \"local\"\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env, hasEnv := os.LookupEnv(GO_ENV)
+	os.Unsetenv(GO_ENV)
+	defer func() {
+		if hasEnv {
+			os.Setenv(GO_ENV, env)
+		} else {
+			os.Unsetenv(GO_ENV)
+		}
+	}()
+
+	base := filepath.Join(dir, "app.ini")
+	p, watched, err := parseProfiles(base)
+	if err != nil {
+		t.Fatalf("parseProfiles err: %v", err)
+	}
+	expectWatched := []string{base, filepath.Join(dir, "app.dev.ini"), filepath.Join(dir, "app.local.ini")}
+	if !reflect.DeepEqual(watched, expectWatched) {
+		t.Errorf("watched files %v, expect %v", watched, expectWatched)
+	}
+	// base < environment profile < local profile
+	for key, expect := range map[string]string{"Host": "local", "UserName": "dev", "Charset": "base"} {
+		if got, _ := p.Value("kelvins-mysql", key); got != expect {
+			t.Errorf("kelvins-mysql.%s = %q, expect %q", key, got, expect)
+		}
+	}
+
+	// GO_ENV selects the profile over kelvins-server.Environment
+	os.Setenv(GO_ENV, "prod")
+	p, _, err = parseProfiles(base)
+	if err != nil {
+		t.Fatalf("parseProfiles err: %v", err)
+	}
+	for key, expect := range map[string]string{"Host": "local", "UserName": "base"} {
+		if got, _ := p.Value("kelvins-mysql", key); got != expect {
+			t.Errorf("GO_ENV=prod kelvins-mysql.%s = %q, expect %q", key, got, expect)
+		}
+	}
+}
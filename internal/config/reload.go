@@ -0,0 +1,259 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// configMutex guards provider, snapshots and userSections
+	configMutex  sync.Mutex
+	loadedFiles  []string
+	snapshots    map[string]string
+	userSections = map[string]interface{}{}
+
+	// settings holds the value of every mapped section published by the load or the last reload, section => value.
+	// a reload publishes a new copy and never modifies a published value, so it is read without locking.
+	settings sync.Map
+
+	handlersMutex  sync.RWMutex
+	changeHandlers = map[string][]func(section string, value interface{}){}
+)
+
+// OnChange registers fn which is called after section changed by a reload.
+// value is the new copy of the section, of the type of the kelvins.*Setting global or of the struct passed to MapConfig,
+// nil when the section is not mapped. the globals and the structs passed to MapConfig keep the values loaded at startup.
+func OnChange(section string, fn func(section string, value interface{})) {
+	if fn == nil {
+		return
+	}
+	handlersMutex.Lock()
+	defer handlersMutex.Unlock()
+	changeHandlers[section] = append(changeHandlers[section], fn)
+}
+
+// Setting returns the value of section published by the load or the last reload, nil when the section is not mapped.
+// it is safe for concurrent use, the returned value must not be modified.
+func Setting(section string) interface{} {
+	v, _ := settings.Load(section)
+	return v
+}
+
+// Reload re-parses the config file and its profiles, merges the remote config, re-maps every changed section and notifies OnChange handlers.
+// nothing is applied when a changed section is invalid, the sections are reloaded again by the next reload.
+func Reload() error {
+	changed, err := reload()
+	if err != nil {
+		return err
+	}
+	for _, c := range changed {
+		notifyChange(c.section, c.value)
+	}
+	return nil
+}
+
+type sectionChange struct {
+	section string
+	value   interface{}
+}
+
+func reload() ([]sectionChange, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if len(loadedFiles) == 0 {
+		return nil, fmt.Errorf("config is not loaded")
+	}
+	next, files, err := parseProfiles(loadedFiles[0])
+	if err != nil {
+		return nil, err
+	}
+	// the cached remote release keeps overriding the file until a new one is received
+	err = mergeRemote(next, remoteRelease)
+	if err != nil {
+		return nil, err
+	}
+	applyFlagSections(next)
+	loadedFiles = files
+	nextSnapshots := fingerprints(next)
+	sections := diffSnapshots(snapshots, nextSnapshots)
+	if len(sections) == 0 {
+		return nil, nil
+	}
+
+	// every changed section is mapped and validated against next before anything is applied
+	var (
+		changed  []sectionChange
+		problems []string
+		sources  = map[string]map[string]Source{}
+	)
+	for _, section := range sections {
+		if !next.HasSection(section) {
+			log.Printf("[info] Config section %s is removed, the last value is kept", section)
+			continue
+		}
+		sources[section] = map[string]Source{}
+		value, sectionProblems := remapSection(next, section, sources[section])
+		if len(sectionProblems) > 0 {
+			problems = append(problems, sectionProblems...)
+			continue
+		}
+		changed = append(changed, sectionChange{section: section, value: value})
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	provider = next
+	snapshots = nextSnapshots
+	for _, c := range changed {
+		log.Printf("[info] Config section %s reloaded", c.section)
+		setOrigins(c.section, sources[c.section])
+		if c.value != nil {
+			settings.Store(c.section, c.value)
+		}
+	}
+	return changed, nil
+}
+
+// remapSection maps section of p onto a copy of the published value and validates it, the copy is returned.
+// nil is returned when the section is mapped neither by the framework nor by MapConfig.
+func remapSection(p Provider, section string, sources map[string]Source) (interface{}, []string) {
+	current := Setting(section)
+	if current == nil {
+		for _, sec := range defaultSections {
+			if sec.name == section {
+				// a section added by the reload, the global is nil
+				current = reflect.ValueOf(sec.target).Elem().Interface()
+			}
+		}
+	}
+	if current == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(current)
+	if rv.Kind() != reflect.Ptr {
+		return nil, nil
+	}
+	v := reflect.New(rv.Type().Elem())
+	if !rv.IsNil() {
+		// keep the fields filled by the framework at runtime
+		v.Elem().Set(rv.Elem())
+	}
+	if secretProblems := overlaySection(p, section, v.Interface(), sources); len(secretProblems) > 0 {
+		return nil, secretProblems
+	}
+	if err := p.MapTo(section, v.Interface()); err != nil {
+		return nil, []string{fmt.Sprintf("section %q map to setting err: %v", section, err)}
+	}
+	if problems := validateStruct(section, v.Interface()); len(problems) > 0 {
+		return nil, problems
+	}
+	return v.Interface(), nil
+}
+
+func notifyChange(section string, value interface{}) {
+	handlersMutex.RLock()
+	handlers := append([]func(string, interface{}){}, changeHandlers[section]...)
+	handlersMutex.RUnlock()
+	for _, fn := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[err] Config section %s OnChange handler panic: %v", section, r)
+				}
+			}()
+			fn(section, value)
+		}()
+	}
+}
+
+// fingerprints renders every section as sorted key=value lines, so sections can be compared after a reload.
+func fingerprints(p Provider) map[string]string {
+	result := make(map[string]string)
+	for _, section := range p.Sections() {
+		keys := p.Keys(section)
+		sort.Strings(keys)
+		var fingerprint string
+		for _, key := range keys {
+			value, _ := p.Value(section, key)
+			fingerprint += fmt.Sprintf("%s=%#v\n", key, value)
+		}
+		result[section] = fingerprint
+	}
+	return result
+}
+
+func diffSnapshots(prev, next map[string]string) []string {
+	var changed []string
+	for section, fingerprint := range next {
+		if old, ok := prev[section]; !ok || old != fingerprint {
+			changed = append(changed, section)
+		}
+	}
+	for section := range prev {
+		if _, ok := next[section]; !ok {
+			changed = append(changed, section)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Watch reloads the config when the file or a profile is modified (checked every interval, disabled when interval <= 0)
+// or the process receives SIGHUP, until stop is closed.
+func Watch(interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+		defer ticker.Stop()
+	}
+	defer signal.Stop(hup)
+
+	modTime := fileModTime()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			log.Printf("[info] Config reload by SIGHUP")
+		case <-tick:
+			current := fileModTime()
+			if current.Equal(modTime) {
+				continue
+			}
+			modTime = current
+			log.Printf("[info] Config reload by file modified")
+		}
+		if err := Reload(); err != nil {
+			log.Printf("[err] Config reload err: %v", err)
+		}
+	}
+}
+
+// fileModTime returns the latest modification time of the config file and its profiles.
+func fileModTime() time.Time {
+	configMutex.Lock()
+	files := append([]string{}, loadedFiles...)
+	configMutex.Unlock()
+	var latest time.Time
+	for _, filename := range files {
+		info, err := os.Stat(filename)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type reloadSetting struct {
+	Host string `validate:"required"`
+	Port int    `validate:"min=1"`
+}
+
+func TestReload_AppliesOnlyValidChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kelvins-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.ini")
+	write := func(content string) {
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("[app-a]\nHost = \"a1\"\nPort = 1\n[app-b]\nHost = \"b1\"\nPort = 1\n")
+
+	p, err := ParseFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMutex.Lock()
+	provider, loadedFiles, snapshots = p, []string{filename}, fingerprints(p)
+	configMutex.Unlock()
+	defer func() {
+		configMutex.Lock()
+		provider, loadedFiles, snapshots = nil, nil, nil
+		configMutex.Unlock()
+		settings.Delete("app-a")
+		settings.Delete("app-b")
+		handlersMutex.Lock()
+		delete(changeHandlers, "app-a")
+		delete(changeHandlers, "app-b")
+		handlersMutex.Unlock()
+	}()
+
+	a, b := new(reloadSetting), new(reloadSetting)
+	if err := MapConfigE("app-a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := MapConfigE("app-b", b); err != nil {
+		t.Fatal(err)
+	}
+	notified := map[string]*reloadSetting{}
+	for _, section := range []string{"app-a", "app-b"} {
+		OnChange(section, func(section string, value interface{}) {
+			notified[section] = value.(*reloadSetting)
+		})
+	}
+
+	// app-b is valid but app-a is not, nothing is applied
+	write("[app-a]\nHost = \"a2\"\nPort = 0\n[app-b]\nHost = \"b2\"\nPort = 1\n")
+	if err := Reload(); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if len(notified) != 0 {
+		t.Fatalf("no handler should be called, got %v", notified)
+	}
+	if got := Setting("app-b").(*reloadSetting).Host; got != "b1" {
+		t.Fatalf("app-b should not be applied, Host = %q", got)
+	}
+
+	// the fixed file applies both changes, including app-b rejected above
+	write("[app-a]\nHost = \"a2\"\nPort = 1\n[app-b]\nHost = \"b2\"\nPort = 1\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload err: %v", err)
+	}
+	for section, host := range map[string]string{"app-a": "a2", "app-b": "b2"} {
+		if notified[section] == nil || notified[section].Host != host {
+			t.Errorf("%s handler value = %+v, expect Host %q", section, notified[section], host)
+		}
+		if got := Setting(section).(*reloadSetting); got != notified[section] {
+			t.Errorf("%s Setting = %+v, expect the notified value", section, got)
+		}
+	}
+	if a.Host != "a1" || b.Host != "b1" {
+		t.Errorf("the structs passed to MapConfig should keep the startup values, got %q %q", a.Host, b.Host)
+	}
+}
@@ -0,0 +1,336 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/config/setting"
+	"gitee.com/kelvins-io/kelvins/internal/util"
+	"go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// RemotePrefix is the default etcd key prefix of remote config
+	RemotePrefix = "/kelvins-config"
+
+	remoteTimeout = 10 * time.Second
+)
+
+// RemoteRelease is one published version of the remote config of an app environment.
+type RemoteRelease struct {
+	Version      int64  `json:"version"`
+	Format       string `json:"format"`
+	Content      string `json:"content"`
+	RollbackFrom int64  `json:"rollback_from,omitempty"`
+	PublishedAt  string `json:"published_at"`
+}
+
+var (
+	// remoteKey, remoteRelease and remoteRevision are guarded by configMutex
+	remoteKey     string
+	remoteRelease *RemoteRelease
+	// remoteRevision is the etcd revision remoteRelease is read at, the watch starts after it
+	remoteRevision int64
+	remoteKeys     map[string]map[string]bool
+)
+
+// RemoteKey returns the etcd key of the remote config eg: /kelvins-config/<app>/<env>
+func RemoteKey(prefix, appName, environment string) string {
+	if prefix == "" {
+		prefix = RemotePrefix
+	}
+	return path.Join(prefix, appName, environment)
+}
+
+func remoteHistoryKey(key string, version int64) string {
+	return fmt.Sprintf("%s/history/%010d", key, version)
+}
+
+// setupRemote fetches the remote config when kelvins-config.RemoteEnable is set, must hold configMutex.
+func setupRemote(application *kelvins.Application) error {
+	configSetting := new(setting.ConfigSettingS)
+	if !sectionPresent(SectionConfig, configSetting) {
+		return nil
+	}
+	if secretProblems := overlaySection(provider, SectionConfig, configSetting, map[string]Source{}); len(secretProblems) > 0 {
+		return &ValidationError{Problems: secretProblems}
+	}
+	if err := provider.MapTo(SectionConfig, configSetting); err != nil {
+		return err
+	}
+	if !configSetting.RemoteEnable {
+		return nil
+	}
+
+	appName := application.Name
+	environment := DefaultEnvironmentProd
+	serverSetting := new(setting.ServerSettingS)
+	if provider.HasSection(SectionServer) {
+		if secretProblems := overlaySection(provider, SectionServer, serverSetting, map[string]Source{}); len(secretProblems) > 0 {
+			return &ValidationError{Problems: secretProblems}
+		}
+		if err := provider.MapTo(SectionServer, serverSetting); err != nil {
+			return err
+		}
+		if serverSetting.AppName != "" {
+			appName = serverSetting.AppName
+		}
+		if serverSetting.Environment != "" {
+			environment = serverSetting.Environment
+		}
+	}
+	if SelectedEnvironment() != "" {
+		environment = SelectedEnvironment()
+	}
+	if appName == "" {
+		return fmt.Errorf("remote config requires the application name")
+	}
+
+	remoteKey = RemoteKey(configSetting.RemotePrefix, appName, environment)
+	release, revision, err := getRemoteRelease(remoteKey)
+	if err != nil {
+		return fmt.Errorf("remote config(%v) err: %v", remoteKey, err)
+	}
+	remoteRelease = release
+	remoteRevision = revision
+	if release == nil {
+		log.Printf("[info] Remote config %s has no release", remoteKey)
+		return nil
+	}
+	log.Printf("[info] Remote config %s version %d loaded", remoteKey, release.Version)
+	return mergeRemote(provider, release)
+}
+
+// mergeRemote overrides the keys of p with the keys of release.
+func mergeRemote(p Provider, release *RemoteRelease) error {
+	remoteKeys = map[string]map[string]bool{}
+	if release == nil {
+		return nil
+	}
+	remote, err := Parse(release.Format, []byte(release.Content))
+	if err != nil {
+		return fmt.Errorf("parse remote config version %d err: %v", release.Version, err)
+	}
+	mergeProvider(p, remote)
+	for _, section := range remote.Sections() {
+		remoteKeys[section] = map[string]bool{}
+		for _, key := range remote.Keys(section) {
+			remoteKeys[section][normalizeKey(key)] = true
+		}
+	}
+	return nil
+}
+
+func isRemoteKey(section, key string) bool {
+	return remoteKeys[section][normalizeKey(key)]
+}
+
+// WatchRemote reloads the config when a new remote release is published, until stop is closed.
+func WatchRemote(stop <-chan struct{}) {
+	configMutex.Lock()
+	key := remoteKey
+	configMutex.Unlock()
+	if key == "" {
+		return
+	}
+	cli, err := util.GetEtcdV3(GetEtcdV3ServerURLs())
+	if err != nil {
+		log.Printf("[err] Remote config watch(%v) err: %v", key, err)
+		return
+	}
+	defer util.TrackEtcdV3Watch()()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		// watch after the revision of the applied release, a release published in between is not missed
+		configMutex.Lock()
+		revision := remoteRevision
+		configMutex.Unlock()
+		for resp := range cli.Watch(ctx, key, clientv3.WithRev(revision+1)) {
+			if resp.Err() != nil {
+				log.Printf("[err] Remote config watch(%v) err: %v", key, resp.Err())
+				continue
+			}
+			fetchRemote(key)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		// the revision may be compacted while the watch is down, the release is fetched again before watching
+		fetchRemote(key)
+	}
+}
+
+// fetchRemote fetches the release of key and reloads the config with it.
+func fetchRemote(key string) {
+	release, revision, err := getRemoteRelease(key)
+	if err != nil {
+		log.Printf("[err] Remote config(%v) err: %v", key, err)
+		return
+	}
+	configMutex.Lock()
+	received := release != nil && (remoteRelease == nil || remoteRelease.Version != release.Version)
+	remoteRelease = release
+	remoteRevision = revision
+	configMutex.Unlock()
+	if received {
+		log.Printf("[info] Remote config %s version %d received", key, release.Version)
+	}
+	if err := Reload(); err != nil {
+		log.Printf("[err] Config reload err: %v", err)
+	}
+}
+
+// getRemoteRelease returns the release of key, nil if not published, and the etcd revision it is read at.
+func getRemoteRelease(key string) (*RemoteRelease, int64, error) {
+	cli, err := util.GetEtcdV3(GetEtcdV3ServerURLs())
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, resp.Header.Revision, nil
+	}
+	release := new(RemoteRelease)
+	err = json.Unmarshal(resp.Kvs[0].Value, release)
+	if err != nil {
+		return nil, 0, fmt.Errorf("json.Unmarshal err: %v, key: %v", err, key)
+	}
+	return release, resp.Header.Revision, nil
+}
+
+// PublishRemote publishes content as the next version of the remote config of appName in environment.
+// format is a file extension eg: .ini .yaml
+func PublishRemote(appName, environment, format string, content []byte) (*RemoteRelease, error) {
+	if _, err := Parse(format, content); err != nil {
+		return nil, fmt.Errorf("remote config content is invalid: %v", err)
+	}
+	return publishRemote(remotePublishKey(appName, environment), &RemoteRelease{
+		Format:  format,
+		Content: string(content),
+	})
+}
+
+// RollbackRemote publishes the content of version again as the next version.
+func RollbackRemote(appName, environment string, version int64) (*RemoteRelease, error) {
+	key := remotePublishKey(appName, environment)
+	cli, err := util.GetEtcdV3(GetEtcdV3ServerURLs())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	resp, err := cli.Get(ctx, remoteHistoryKey(key, version))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("remote config %s version %d not exist", key, version)
+	}
+	old := new(RemoteRelease)
+	if err := json.Unmarshal(resp.Kvs[0].Value, old); err != nil {
+		return nil, err
+	}
+	return publishRemote(key, &RemoteRelease{
+		Format:       old.Format,
+		Content:      old.Content,
+		RollbackFrom: old.Version,
+	})
+}
+
+// RemoteReleases lists every published version of the remote config, oldest first.
+func RemoteReleases(appName, environment string) ([]*RemoteRelease, error) {
+	key := remotePublishKey(appName, environment)
+	cli, err := util.GetEtcdV3(GetEtcdV3ServerURLs())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	resp, err := cli.Get(ctx, key+"/history/", clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+	releases := make([]*RemoteRelease, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		release := new(RemoteRelease)
+		if err := json.Unmarshal(kv.Value, release); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal err: %v, key: %s", err, kv.Key)
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+func remotePublishKey(appName, environment string) string {
+	prefix := RemotePrefix
+	if kelvins.ConfigSetting != nil && kelvins.ConfigSetting.RemotePrefix != "" {
+		prefix = kelvins.ConfigSetting.RemotePrefix
+	}
+	return RemoteKey(prefix, appName, environment)
+}
+
+// publishRemote writes release to the history and the current key in one transaction.
+func publishRemote(key string, release *RemoteRelease) (*RemoteRelease, error) {
+	cli, err := util.GetEtcdV3(GetEtcdV3ServerURLs())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var modRevision int64
+	if len(resp.Kvs) > 0 {
+		current := new(RemoteRelease)
+		if err := json.Unmarshal(resp.Kvs[0].Value, current); err != nil {
+			return nil, err
+		}
+		release.Version = current.Version + 1
+		modRevision = resp.Kvs[0].ModRevision
+	} else {
+		release.Version = 1
+	}
+	release.Format = strings.ToLower(release.Format)
+	release.PublishedAt = time.Now().Format(kelvins.ResponseTimeLayout)
+	value, err := json.Marshal(release)
+	if err != nil {
+		return nil, err
+	}
+	// fails when another publisher wins the race
+	txn, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(remoteHistoryKey(key, release.Version), string(value)), clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !txn.Succeeded {
+		return nil, fmt.Errorf("remote config %s is published concurrently, please retry", key)
+	}
+	return release, nil
+}
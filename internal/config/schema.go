@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SectionSchema describes a framework section and the setting struct it maps to.
+type SectionSchema struct {
+	Name   string
+	Type   string // setting struct name eg: MysqlSettingS
+	Fields []FieldSchema
+}
+
+// FieldSchema describes a key of a section.
+type FieldSchema struct {
+	Key     string
+	Field   string // struct field name
+	Kind    string // string int bool float duration list
+	Rules   []string
+	Default string
+}
+
+// Schema reflects over the setting structs of the framework sections, in the order they are loaded.
+// the legacy section kelvins-auth is left out, it is the same as kelvins-rpc-auth.
+func Schema() []SectionSchema {
+	var result []SectionSchema
+	for _, sec := range defaultSections {
+		if sec.name == SectionAuth {
+			continue
+		}
+		t := reflect.TypeOf(sec.target).Elem().Elem()
+		schema := SectionSchema{Name: sec.name, Type: t.Name()}
+		keys := settingKeys(reflect.New(t).Interface())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := fieldKey(field)
+			if !containsString(keys, key) {
+				continue
+			}
+			f := FieldSchema{Key: key, Field: field.Name, Kind: fieldKind(field)}
+			if tag := field.Tag.Get("validate"); tag != "" {
+				f.Rules = strings.Split(tag, ",")
+			}
+			f.Default, _ = lookupDefault(sec.name, key)
+			schema.Fields = append(schema.Fields, f)
+		}
+		result = append(result, schema)
+	}
+	return result
+}
+
+func fieldKind(field reflect.StructField) string {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "duration" {
+			return "duration"
+		}
+	}
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	}
+	return "string"
+}
+
+// describe returns the description of typ or typ.field from descriptions, empty when absent.
+func describe(descriptions map[string]string, name string) string {
+	if descriptions == nil {
+		return ""
+	}
+	return descriptions[name]
+}
+
+func ruleArg(rules []string, name string) (string, bool) {
+	for _, rule := range rules {
+		if rule == name {
+			return "", true
+		}
+		if strings.HasPrefix(rule, name+"=") {
+			return strings.TrimPrefix(rule, name+"="), true
+		}
+	}
+	return "", false
+}
+
+// fieldComment renders the description and the rules of f as one line.
+func fieldComment(typ string, f FieldSchema, descriptions map[string]string) string {
+	var parts []string
+	if d := describe(descriptions, typ+"."+f.Field); d != "" {
+		parts = append(parts, d)
+	}
+	parts = append(parts, f.Kind)
+	parts = append(parts, f.Rules...)
+	return strings.Join(parts, "; ")
+}
+
+func sampleValue(f FieldSchema, quote func(string) string) string {
+	if f.Default != "" {
+		if f.Kind == "string" || f.Kind == "duration" {
+			return quote(f.Default)
+		}
+		return f.Default
+	}
+	switch f.Kind {
+	case "bool":
+		return "false"
+	case "int", "float":
+		return "0"
+	case "list":
+		return ""
+	}
+	return quote("")
+}
+
+// Sample renders a commented sample config of every framework section, format is .ini or .yaml.
+// descriptions holds the doc comments keyed by type or type.field eg: MysqlSettingS.Host, it may be nil.
+func Sample(format string, descriptions map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch strings.ToLower(format) {
+	case ".ini", "ini":
+		for _, sec := range Schema() {
+			writeSectionComment(&buf, "; ", sec, descriptions)
+			fmt.Fprintf(&buf, "[%s]\n", sec.Name)
+			for _, f := range sec.Fields {
+				fmt.Fprintf(&buf, "; %s\n%s = %s\n", fieldComment(sec.Type, f, descriptions), f.Key, sampleValue(f, strconv.Quote))
+			}
+			buf.WriteString("\n")
+		}
+	case ".yaml", "yaml", ".yml", "yml":
+		for _, sec := range Schema() {
+			writeSectionComment(&buf, "# ", sec, descriptions)
+			fmt.Fprintf(&buf, "%s:\n", sec.Name)
+			for _, f := range sec.Fields {
+				value := sampleValue(f, strconv.Quote)
+				if f.Kind == "list" && f.Default == "" {
+					value = "[]"
+				}
+				fmt.Fprintf(&buf, "  # %s\n  %s: %s\n", fieldComment(sec.Type, f, descriptions), f.Key, value)
+			}
+			buf.WriteString("\n")
+		}
+	default:
+		return nil, fmt.Errorf("sample format %q is not supported, use .ini or .yaml", format)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeSectionComment(buf *bytes.Buffer, prefix string, sec SectionSchema, descriptions map[string]string) {
+	comment := sec.Type
+	if d := describe(descriptions, sec.Type); d != "" {
+		comment = d
+	}
+	fmt.Fprintf(buf, "%s%s\n", prefix, comment)
+}
+
+// JSONSchema renders a JSON Schema (draft-07) of the framework sections, for editors validating yaml or json config files.
+// unknown sections are allowed for custom config. descriptions is the same as Sample.
+func JSONSchema(descriptions map[string]string) ([]byte, error) {
+	properties := make(map[string]interface{})
+	for _, sec := range Schema() {
+		fields := make(map[string]interface{})
+		var required []string
+		for _, f := range sec.Fields {
+			property := jsonSchemaProperty(f)
+			if d := describe(descriptions, sec.Type+"."+f.Field); d != "" {
+				property["description"] = d
+			}
+			fields[f.Key] = property
+			if _, ok := ruleArg(f.Rules, "required"); ok {
+				required = append(required, f.Key)
+			}
+		}
+		section := map[string]interface{}{
+			"type":       "object",
+			"properties": fields,
+		}
+		if d := describe(descriptions, sec.Type); d != "" {
+			section["description"] = d
+		}
+		if len(required) > 0 {
+			sort.Strings(required)
+			section["required"] = required
+		}
+		properties[sec.Name] = section
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "kelvins config",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": true,
+	}, "", "  ")
+}
+
+func jsonSchemaProperty(f FieldSchema) map[string]interface{} {
+	property := make(map[string]interface{})
+	switch f.Kind {
+	case "bool":
+		property["type"] = "boolean"
+	case "int":
+		property["type"] = "integer"
+	case "float":
+		property["type"] = "number"
+	case "list":
+		property["type"] = "array"
+		property["items"] = map[string]interface{}{"type": "string"}
+	case "duration":
+		property["type"] = "string"
+		property["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	default:
+		property["type"] = "string"
+	}
+	if arg, ok := ruleArg(f.Rules, "oneof"); ok {
+		// an empty value is valid, required is a separate rule
+		property["enum"] = append([]string{""}, strings.Fields(arg)...)
+	}
+	minKeyword, maxKeyword := "minimum", "maximum"
+	switch f.Kind {
+	case "string":
+		minKeyword, maxKeyword = "minLength", "maxLength"
+	case "list":
+		minKeyword, maxKeyword = "minItems", "maxItems"
+	}
+	for rule, keyword := range map[string]string{"min": minKeyword, "max": maxKeyword} {
+		arg, ok := ruleArg(f.Rules, rule)
+		if !ok {
+			continue
+		}
+		if bound, err := strconv.ParseFloat(arg, 64); err == nil {
+			property[keyword] = bound
+		}
+	}
+	if f.Default != "" {
+		property["default"] = f.Default
+	}
+	return property
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestSample_Parses keeps the generated sample loadable when the setting structs change.
+func TestSample_Parses(t *testing.T) {
+	for _, format := range []string{".ini", ".yaml"} {
+		sample, err := Sample(format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Parse(format, sample)
+		if err != nil {
+			t.Fatalf("parse %s sample err: %v\n%s", format, err, sample)
+		}
+		for _, sec := range Schema() {
+			if !p.HasSection(sec.Name) {
+				t.Errorf("%s sample lacks section %s", format, sec.Name)
+			}
+		}
+	}
+
+	schema, err := JSONSchema(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(schema) {
+		t.Fatal("JSONSchema returns invalid json")
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// ENV_SECRET_KEY is the key which decrypts ENC(...) config values
+	ENV_SECRET_KEY = "KELVINS_SECRET_KEY"
+	// ENV_SECRET_KEY_FILE is the file holding the key, used when KELVINS_SECRET_KEY is empty
+	ENV_SECRET_KEY_FILE = "KELVINS_SECRET_KEY_FILE"
+
+	secretPrefix = "ENC("
+	secretSuffix = ")"
+)
+
+// SecretResolver returns the secret referenced by ref, ref is the config value without the scheme:// prefix.
+type SecretResolver func(ref string) (string, error)
+
+var (
+	secretResolversMutex sync.RWMutex
+	secretResolvers      = map[string]SecretResolver{
+		"file": resolveFileSecret,
+		"env":  resolveEnvSecret,
+	}
+	// the keys whose scheme://ref values are resolved, section => lower case key
+	secretSchemeKeys = map[string]map[string]bool{}
+)
+
+// localSecretSchemes read the files and the env vars of the process, they are never resolved for remote values,
+// otherwise the publisher of the remote config could read them out through the config.
+var localSecretSchemes = map[string]bool{
+	"file": true,
+	"env":  true,
+}
+
+// RegisterSecretResolver registers resolver for config values like scheme://ref eg: vault://secret/mysql
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMutex.Lock()
+	defer secretResolversMutex.Unlock()
+	secretResolvers[scheme] = resolver
+}
+
+// EnableSecretScheme resolves the scheme://ref values of section.key by the secret resolvers,
+// the values of other keys are taken as they are, only ENC(...) is decrypted for every key.
+// call it before the section is mapped.
+func EnableSecretScheme(section, key string) {
+	secretResolversMutex.Lock()
+	defer secretResolversMutex.Unlock()
+	if secretSchemeKeys[section] == nil {
+		secretSchemeKeys[section] = map[string]bool{}
+	}
+	secretSchemeKeys[section][strings.ToLower(key)] = true
+}
+
+func secretSchemeEnabled(section, key string) bool {
+	secretResolversMutex.RLock()
+	defer secretResolversMutex.RUnlock()
+	return secretSchemeKeys[section][strings.ToLower(key)]
+}
+
+func resolveFileSecret(ref string) (string, error) {
+	data, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func resolveEnvSecret(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("env %s is not set", ref)
+	}
+	return value, nil
+}
+
+// resolveSecret decrypts ENC(...) values, and resolves scheme://ref values of a registered scheme
+// if the scheme is enabled for section.key, file:// and env:// are not resolved for the remote values.
+// other values are returned as is.
+func resolveSecret(section, key, value string, source Source) (string, bool, error) {
+	if strings.HasPrefix(value, secretPrefix) && strings.HasSuffix(value, secretSuffix) {
+		key, err := secretKey()
+		if err != nil {
+			return "", true, err
+		}
+		plain, err := DecryptSecret(key, value)
+		return plain, true, err
+	}
+	i := strings.Index(value, "://")
+	if i <= 0 || !secretSchemeEnabled(section, key) {
+		return value, false, nil
+	}
+	scheme := value[:i]
+	if source == SourceRemote && localSecretSchemes[scheme] {
+		return value, false, fmt.Errorf("%s:// is not allowed in the remote config", scheme)
+	}
+	secretResolversMutex.RLock()
+	resolver, ok := secretResolvers[scheme]
+	secretResolversMutex.RUnlock()
+	if !ok {
+		return value, false, nil
+	}
+	plain, err := resolver(value[i+3:])
+	return plain, true, err
+}
+
+// secretKey reads the key from env KELVINS_SECRET_KEY or the file named by env KELVINS_SECRET_KEY_FILE.
+func secretKey() ([]byte, error) {
+	if key := os.Getenv(ENV_SECRET_KEY); key != "" {
+		return []byte(key), nil
+	}
+	if filename := os.Getenv(ENV_SECRET_KEY_FILE); filename != "" {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file err: %v", err)
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	}
+	return nil, fmt.Errorf("secret key is not set, set env %s or %s", ENV_SECRET_KEY, ENV_SECRET_KEY_FILE)
+}
+
+func newSecretCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("secret key is empty")
+	}
+	// any key length is accepted, AES-256 uses its sha256 sum
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts plaintext by AES-GCM and returns ENC(base64(nonce+ciphertext)).
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	aead, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed) + secretSuffix, nil
+}
+
+// DecryptSecret decrypts a value returned by EncryptSecret.
+func DecryptSecret(key []byte, value string) (string, error) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, secretPrefix), secretSuffix)
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("secret is not base64: %v", err)
+	}
+	aead, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("secret is too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("secret decrypt err, the key may be wrong: %v", err)
+	}
+	return string(plain), nil
+}
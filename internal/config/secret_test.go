@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	key := []byte("kelvins-secret-key")
+	encrypted, err := EncryptSecret(key, "mysql-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(ENV_SECRET_KEY, string(key))
+	defer os.Unsetenv(ENV_SECRET_KEY)
+	os.Setenv("KELVINS_TEST_REDIS_PASSWORD", "redis-password")
+	defer os.Unsetenv("KELVINS_TEST_REDIS_PASSWORD")
+
+	EnableSecretScheme("kelvins-redis", "Password")
+	cases := []struct {
+		key, value, expect string
+		source             Source
+		resolved           bool
+	}{
+		{"Password", encrypted, "mysql-password", SourceFile, true},
+		{"Password", "env://KELVINS_TEST_REDIS_PASSWORD", "redis-password", SourceFile, true},
+		{"password", "env://KELVINS_TEST_REDIS_PASSWORD", "redis-password", SourceEnv, true},
+		// the schemes are resolved for the enabled keys only
+		{"Host", "env://KELVINS_TEST_REDIS_PASSWORD", "env://KELVINS_TEST_REDIS_PASSWORD", SourceFile, false},
+		{"Host", "file:///tmp/redis", "file:///tmp/redis", SourceFile, false},
+		{"Host", encrypted, "mysql-password", SourceRemote, true},
+		{"Password", "redis://127.0.0.1:6379", "redis://127.0.0.1:6379", SourceFile, false},
+		{"Password", "plain", "plain", SourceFile, false},
+	}
+	for _, c := range cases {
+		value, resolved, err := resolveSecret("kelvins-redis", c.key, c.value, c.source)
+		if err != nil {
+			t.Fatalf("resolve %q err: %v", c.value, err)
+		}
+		if value != c.expect || resolved != c.resolved {
+			t.Errorf("resolve %q got %q %v, expect %q %v", c.value, value, resolved, c.expect, c.resolved)
+		}
+	}
+
+	// the remote config cannot read the local files and env vars
+	for _, value := range []string{"env://KELVINS_TEST_REDIS_PASSWORD", "file:///etc/shadow"} {
+		if plain, _, err := resolveSecret("kelvins-redis", "Password", value, SourceRemote); err == nil || plain == "redis-password" {
+			t.Errorf("resolve remote %q got %q, expect err", value, plain)
+		}
+	}
+
+	if _, err := DecryptSecret([]byte("wrong-key"), encrypted); err == nil {
+		t.Error("expected err with wrong key")
+	}
+}
@@ -0,0 +1,461 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// Provider is a parsed config document, every top level section maps to a setting struct.
+type Provider interface {
+	// Sections returns the section names of the document.
+	Sections() []string
+	// HasSection reports whether the section exists.
+	HasSection(section string) bool
+	// Keys returns the keys of section.
+	Keys(section string) []string
+	// Value returns the raw value of section.key.
+	Value(section, key string) (interface{}, bool)
+	// Set overrides section.key, the section is created when absent.
+	Set(section, key string, value interface{})
+	// MapTo maps section to struct v.
+	MapTo(section string, v interface{}) error
+}
+
+// ParseFunc parses config file content into a Provider.
+type ParseFunc func(data []byte) (Provider, error)
+
+var (
+	formatsMutex sync.RWMutex
+	formats      = map[string]ParseFunc{
+		".ini":  parseINI,
+		".yaml": parseYAML,
+		".yml":  parseYAML,
+		".toml": parseTOML,
+		".json": parseJSON,
+	}
+)
+
+// RegisterFormat registers a parser for config files with the extension ext eg: ".hcl"
+func RegisterFormat(ext string, parse ParseFunc) {
+	formatsMutex.Lock()
+	defer formatsMutex.Unlock()
+	formats[strings.ToLower(ext)] = parse
+}
+
+// ParseFile parses the config file, the format is picked by the file extension.
+func ParseFile(filename string) (Provider, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(filepath.Ext(filename), data)
+}
+
+// Parse parses data in the format of extension ext.
+func Parse(ext string, data []byte) (Provider, error) {
+	formatsMutex.RLock()
+	parse, ok := formats[strings.ToLower(ext)]
+	formatsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format %q", ext)
+	}
+	return parse(data)
+}
+
+// iniProvider keeps ini files on gopkg.in/ini.v1 so existing app.ini behave exactly as before.
+type iniProvider struct {
+	file *ini.File
+}
+
+func parseINI(data []byte) (Provider, error) {
+	file, err := ini.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	return &iniProvider{file: file}, nil
+}
+
+func (p *iniProvider) Sections() []string {
+	var sections []string
+	for _, name := range p.file.SectionStrings() {
+		if name == ini.DefaultSection {
+			continue
+		}
+		sections = append(sections, name)
+	}
+	return sections
+}
+
+func (p *iniProvider) HasSection(section string) bool {
+	_, err := p.file.GetSection(section)
+	return err == nil
+}
+
+func (p *iniProvider) Keys(section string) []string {
+	sec, err := p.file.GetSection(section)
+	if err != nil {
+		return nil
+	}
+	return sec.KeyStrings()
+}
+
+func (p *iniProvider) Value(section, key string) (interface{}, bool) {
+	sec, err := p.file.GetSection(section)
+	if err != nil || !sec.HasKey(key) {
+		return nil, false
+	}
+	return sec.Key(key).String(), true
+}
+
+func (p *iniProvider) Set(section, key string, value interface{}) {
+	p.file.Section(section).Key(key).SetValue(stringValue(value))
+}
+
+func (p *iniProvider) MapTo(section string, v interface{}) error {
+	sec, err := p.file.GetSection(section)
+	if err != nil {
+		return err
+	}
+	return sec.MapTo(v)
+}
+
+// mapProvider holds structured documents (yaml, toml, json) which may contain nested values.
+type mapProvider struct {
+	sections map[string]map[string]interface{}
+	order    []string
+}
+
+func parseYAML(data []byte) (Provider, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return newMapProvider(doc)
+}
+
+func parseTOML(data []byte) (Provider, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return newMapProvider(doc)
+}
+
+func parseJSON(data []byte) (Provider, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return newMapProvider(doc)
+}
+
+func newMapProvider(doc map[string]interface{}) (Provider, error) {
+	p := &mapProvider{sections: map[string]map[string]interface{}{}}
+	names := make([]string, 0, len(doc))
+	for name := range doc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		section, ok := normalize(doc[name]).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("section %q should be a mapping of keys", name)
+		}
+		p.sections[name] = section
+		p.order = append(p.order, name)
+	}
+	return p, nil
+}
+
+func (p *mapProvider) Sections() []string {
+	return append([]string(nil), p.order...)
+}
+
+func (p *mapProvider) HasSection(section string) bool {
+	_, ok := p.sections[section]
+	return ok
+}
+
+func (p *mapProvider) Keys(section string) []string {
+	keys := make([]string, 0, len(p.sections[section]))
+	for key := range p.sections[section] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (p *mapProvider) Value(section, key string) (interface{}, bool) {
+	for k, v := range p.sections[section] {
+		if normalizeKey(k) == normalizeKey(key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (p *mapProvider) Set(section, key string, value interface{}) {
+	values, ok := p.sections[section]
+	if !ok {
+		values = map[string]interface{}{}
+		p.sections[section] = values
+		p.order = append(p.order, section)
+	}
+	for k := range values {
+		if normalizeKey(k) == normalizeKey(key) {
+			delete(values, k)
+		}
+	}
+	values[key] = value
+}
+
+func (p *mapProvider) MapTo(section string, v interface{}) error {
+	values, ok := p.sections[section]
+	if !ok {
+		return fmt.Errorf("section %q does not exist", section)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("section %q map to non-pointer %T", section, v)
+	}
+	return decodeValue(rv.Elem(), values, section)
+}
+
+// normalize converts yaml and toml specific containers to map[string]interface{} and []interface{}.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = normalize(item)
+		}
+		return value
+	case []map[string]interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = normalize(item)
+		}
+		return list
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	}
+	return v
+}
+
+// normalizeKey lets MaxIdle, maxIdle, max_idle and max-idle refer to the same field.
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+func stringValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = stringValue(item)
+		}
+		return strings.Join(items, ",")
+	case []string:
+		return strings.Join(value, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decodeValue assigns src to dst, converting between strings, numbers and nested containers.
+func decodeValue(dst reflect.Value, src interface{}, path string) error {
+	if src == nil {
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeValue(dst.Elem(), src, path)
+	case reflect.Interface:
+		sv := reflect.ValueOf(src)
+		if !sv.Type().AssignableTo(dst.Type()) {
+			return fmt.Errorf("%s: cannot assign %T to %s", path, src, dst.Type())
+		}
+		dst.Set(sv)
+		return nil
+	case reflect.String:
+		dst.SetString(stringValue(src))
+		return nil
+	case reflect.Bool:
+		switch value := src.(type) {
+		case bool:
+			dst.SetBool(value)
+			return nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			dst.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value, ok := src.(string); ok {
+			value = strings.TrimSpace(value)
+			if dst.Type() == durationType {
+				d, err := time.ParseDuration(value)
+				if err == nil {
+					dst.SetInt(int64(d))
+					return nil
+				}
+			}
+			i, err := strconv.ParseInt(value, 0, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			dst.SetInt(i)
+			return nil
+		}
+		if f, ok := toFloat(src); ok {
+			if f != float64(int64(f)) {
+				return fmt.Errorf("%s: %v is not an integer", path, src)
+			}
+			dst.SetInt(int64(f))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value, ok := src.(string); ok {
+			u, err := strconv.ParseUint(strings.TrimSpace(value), 0, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			dst.SetUint(u)
+			return nil
+		}
+		if f, ok := toFloat(src); ok {
+			if f < 0 || f != float64(uint64(f)) {
+				return fmt.Errorf("%s: %v is not an unsigned integer", path, src)
+			}
+			dst.SetUint(uint64(f))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if value, ok := src.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+		if f, ok := toFloat(src); ok {
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Slice:
+		var items []interface{}
+		switch value := src.(type) {
+		case []interface{}:
+			items = value
+		case string:
+			// keep the ini style comma separated list working
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		default:
+			items = []interface{}{value}
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for k, item := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(elem, item, path+"."+k); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			break
+		}
+		t := dst.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("ini"), ",")[0]; tag != "" {
+				if tag == "-" {
+					continue
+				}
+				name = tag
+			}
+			for k, item := range m {
+				if normalizeKey(k) != normalizeKey(name) {
+					continue
+				}
+				if err := decodeValue(dst.Field(i), item, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: cannot decode %T into %s", path, src, dst.Type())
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case float64:
+		return value, true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
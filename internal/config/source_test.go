@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+)
+
+type replicaSetting struct {
+	Host   string
+	Weight int
+}
+
+type clusterSetting struct {
+	Host     string
+	MaxIdle  int
+	Tags     []string
+	Replicas []replicaSetting
+}
+
+func TestParse_NestedSection(t *testing.T) {
+	docs := map[string]string{
+		".yaml": `
+kelvins-mysql:
+  host: 127.0.0.1:3306
+  max_idle: 5
+  tags: a,b
+  replicas:
+    - host: 127.0.0.2:3306
+      weight: 2
+`,
+		".toml": `
+[kelvins-mysql]
+Host = "127.0.0.1:3306"
+MaxIdle = 5
+Tags = ["a", "b"]
+[[kelvins-mysql.Replicas]]
+Host = "127.0.0.2:3306"
+Weight = 2
+`,
+		".json": `{"kelvins-mysql":{"Host":"127.0.0.1:3306","MaxIdle":5,"Tags":["a","b"],"Replicas":[{"Host":"127.0.0.2:3306","Weight":2}]}}`,
+	}
+	for ext, doc := range docs {
+		p, err := Parse(ext, []byte(doc))
+		if err != nil {
+			t.Fatalf("%s parse err: %v", ext, err)
+		}
+		var s clusterSetting
+		if err := p.MapTo("kelvins-mysql", &s); err != nil {
+			t.Fatalf("%s map err: %v", ext, err)
+		}
+		if s.Host != "127.0.0.1:3306" || s.MaxIdle != 5 || len(s.Tags) != 2 {
+			t.Fatalf("%s unexpected setting: %+v", ext, s)
+		}
+		if len(s.Replicas) != 1 || s.Replicas[0].Weight != 2 {
+			t.Fatalf("%s unexpected replicas: %+v", ext, s.Replicas)
+		}
+	}
+}
+
+func TestParse_SetOverridesKey(t *testing.T) {
+	p, err := Parse(".yaml", []byte("kelvins-redis:\n  host: 127.0.0.1:6379\n  db: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Set("kelvins-redis", "DB", "3")
+	var s struct {
+		Host string
+		DB   int
+	}
+	if err := p.MapTo("kelvins-redis", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.DB != 3 {
+		t.Fatalf("DB = %d, want 3", s.DB)
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError reports every problem found in the config, so they can be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("config has %d problem(s):\n\t%s", len(e.Problems), strings.Join(e.Problems, "\n\t"))
+}
+
+var (
+	// problems collects the problems found before Check, guarded by configMutex
+	problems []string
+	checked  bool
+)
+
+// Check returns the problems found by LoadDefaultConfig and MapConfig as one *ValidationError.
+// MapConfig calls after Check exit the process on any problem.
+func Check() error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	checked = true
+	if len(problems) == 0 {
+		return nil
+	}
+	err := &ValidationError{Problems: problems}
+	problems = nil
+	return err
+}
+
+// Validate checks the fields of the struct v against their validate tags, section prefixes the reported keys.
+// supported rules, separated by comma:
+//
+//	required            the field is not zero
+//	required_with=Field the field is not zero when Field is not zero
+//	min=n max=n         bounds of a number, or of the length of a string or slice
+//	oneof=a b c         a non empty string is one of the values
+//	duration            a non empty string is parsed by time.ParseDuration eg: 3s 2h
+func Validate(section string, v interface{}) error {
+	result := validateStruct(section, v)
+	if len(result) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: result}
+}
+
+func validateStruct(section string, v interface{}) []string {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var result []string
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || field.PkgPath != "" {
+			continue
+		}
+		name := section + "." + fieldKey(field)
+		for _, rule := range strings.Split(tag, ",") {
+			if problem := checkRule(rv, rv.Field(i), strings.TrimSpace(rule)); problem != "" {
+				result = append(result, name+" "+problem)
+			}
+		}
+	}
+	return result
+}
+
+// fieldKey returns the config key of field, following the ini field naming.
+func fieldKey(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("ini"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return field.Name
+}
+
+func checkRule(parent, value reflect.Value, rule string) string {
+	name, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+	switch name {
+	case "":
+		return ""
+	case "required":
+		if isZero(value) {
+			return "is required"
+		}
+	case "required_with":
+		other := parent.FieldByName(arg)
+		if other.IsValid() && !isZero(other) && isZero(value) {
+			return fmt.Sprintf("is required when %s is set", arg)
+		}
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Sprintf("has invalid rule %q", rule)
+		}
+		n, isLen := measure(value)
+		unit := ""
+		if isLen {
+			unit = " in length"
+		}
+		if name == "min" && n < bound {
+			return fmt.Sprintf("must be at least %v%s, got %v", arg, unit, n)
+		}
+		if name == "max" && n > bound {
+			return fmt.Sprintf("must be at most %v%s, got %v", arg, unit, n)
+		}
+	case "oneof":
+		s := fmt.Sprint(value.Interface())
+		if s == "" {
+			return ""
+		}
+		for _, option := range strings.Fields(arg) {
+			if s == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s], got %q", strings.Join(strings.Fields(arg), " "), s)
+	case "duration":
+		if value.Kind() != reflect.String || value.String() == "" {
+			return ""
+		}
+		if _, err := time.ParseDuration(value.String()); err != nil {
+			return fmt.Sprintf("must be a duration eg: 3s 2h, got %q", value.String())
+		}
+	default:
+		return fmt.Sprintf("has unknown rule %q", rule)
+	}
+	return ""
+}
+
+// measure returns the number value, or the length of strings, slices and maps.
+func measure(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
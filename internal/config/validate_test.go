@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+type validateSetting struct {
+	Host        string `validate:"required"`
+	Password    string `validate:"required_with=Host"`
+	DB          int    `validate:"min=0,max=15"`
+	Environment string `validate:"oneof=dev test release prod"`
+	Timeout     string `validate:"duration"`
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	err := Validate("kelvins-test", &validateSetting{DB: 16, Environment: "staging", Timeout: "3"})
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	problems := err.(*ValidationError).Problems
+	if len(problems) != 4 {
+		t.Fatalf("expected 4 problems, got %d: %v", len(problems), problems)
+	}
+	for i, prefix := range []string{"kelvins-test.Host", "kelvins-test.DB", "kelvins-test.Environment", "kelvins-test.Timeout"} {
+		if !strings.HasPrefix(problems[i], prefix) {
+			t.Errorf("problem %d %q should start with %q", i, problems[i], prefix)
+		}
+	}
+
+	err = Validate("kelvins-test", &validateSetting{Host: "127.0.0.1", Password: "pwd", Environment: "dev", Timeout: "3s"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package metrics_mux
+
+import (
+	"net/http"
+
+	"gitee.com/kelvins-io/kelvins/internal/config"
+)
+
+func GetConfigMux(mux *http.ServeMux) *http.ServeMux {
+	mux.HandleFunc("/debug/config", ConfigHandler)
+	return mux
+}
+
+// ConfigHandler prints the effective config, sensitive values are masked.
+func ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := config.DumpJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(data)
+}
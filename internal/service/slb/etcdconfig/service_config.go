@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"gitee.com/kelvins-io/common/json"
+	"gitee.com/kelvins-io/kelvins/internal/logging"
 	"gitee.com/kelvins-io/kelvins/internal/service/slb"
 	"gitee.com/kelvins-io/kelvins/internal/util"
 	"gitee.com/kelvins-io/kelvins/internal/vars"
-	"github.com/etcd-io/etcd/client"
+	"go.etcd.io/etcd/client/v3"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,19 +20,37 @@ const (
 	DefaultCluster = "cluster"
 )
 
+const (
+	requestTimeout     = 10 * time.Second
+	minRegisterBackoff = 500 * time.Millisecond
+	maxRegisterBackoff = 10 * time.Second
+)
+
 var ErrServiceConfigKeyNotExist = errors.New("service config key not exist")
 
+var emptyCtx = context.Background()
+
 type ServiceConfigClient struct {
 	ServiceLB *slb.ServiceLB
 	Config
 }
 
 type Config struct {
-	ServiceVersion string `json:"service_version"`
-	ServicePort    string `json:"service_port"`
-	ServiceIP      string `json:"service_ip"`
-	ServiceKind    string `json:"service_kind"`
-	LastModified   string `json:"last_modified"`
+	ServiceVersion   string            `json:"service_version"`
+	ServicePort      string            `json:"service_port"`
+	ServiceIP        string            `json:"service_ip"`
+	ServiceKind      string            `json:"service_kind"`
+	LastModified     string            `json:"last_modified"`
+	Hostname         string            `json:"hostname,omitempty"`
+	FrameworkVersion string            `json:"framework_version,omitempty"`
+	BuildCommit      string            `json:"build_commit,omitempty"`
+	Zone             string            `json:"zone,omitempty"`
+	Region           string            `json:"region,omitempty"`
+	Weight           int               `json:"weight,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Protocol         string            `json:"protocol,omitempty"`
+	StartTime        string            `json:"start_time,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
 }
 
 func NewServiceConfigClient(slb *slb.ServiceLB) *ServiceConfigClient {
@@ -46,29 +66,37 @@ func (s *ServiceConfigClient) GetKeyName(serverName string, sequences ...string)
 	return key
 }
 
-func (s *ServiceConfigClient) GetConfig(sequence string) (*Config, error) {
-	cli, err := util.NewEtcd(s.ServiceLB.EtcdServerUrl)
+// newClient returns the shared client of the etcd urls, it must not be closed.
+func (s *ServiceConfigClient) newClient() (*clientv3.Client, error) {
+	cli, err := util.GetEtcdV3(s.ServiceLB.EtcdServerUrl)
 	if err != nil {
-		return nil, fmt.Errorf("util.NewEtcd err: %v，etcdUrl: %v", err, s.ServiceLB.EtcdServerUrl)
+		return nil, fmt.Errorf("util.GetEtcdV3 err: %v，etcdUrl: %v", err, s.ServiceLB.EtcdServerUrl)
 	}
+	return cli, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (s *ServiceConfigClient) GetConfig(sequence string) (*Config, error) {
+	cli, err := s.newClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
 	defer cancel()
 
 	key := s.GetKeyName(s.ServiceLB.ServerName, sequence)
-	serviceInfo, err := client.NewKeysAPI(cli).Get(ctx, key, nil)
+	serviceInfo, err := cli.Get(ctx, key)
 	if err != nil {
-		if client.IsKeyNotFound(err) {
-			return nil, ErrServiceConfigKeyNotExist
-		}
 		return nil, fmt.Errorf("cli.Get err: %v, key: %v", err, key)
 	}
+	if len(serviceInfo.Kvs) == 0 {
+		return nil, ErrServiceConfigKeyNotExist
+	}
 
 	var config Config
-	if len(serviceInfo.Node.Value) > 0 {
-		err = json.Unmarshal(serviceInfo.Node.Value, &config)
+	if len(serviceInfo.Kvs[0].Value) > 0 {
+		err = json.Unmarshal(string(serviceInfo.Kvs[0].Value), &config)
 		if err != nil {
-			return nil, fmt.Errorf("json.Unmarshal err: %v, key: %v,values: %v", err, key, serviceInfo.Node.Value)
+			return nil, fmt.Errorf("json.Unmarshal err: %v, key: %v,values: %v", err, key, string(serviceInfo.Kvs[0].Value))
 		}
 	}
 
@@ -79,92 +107,157 @@ func (s *ServiceConfigClient) GetConfig(sequence string) (*Config, error) {
 	return &config, nil
 }
 
-func (s *ServiceConfigClient) ClearConfig(sequence string) error {
-	cli, err := util.NewEtcd(s.ServiceLB.EtcdServerUrl)
+// LeaseAlive reports whether the key is kept by a lease not expired yet, a key without lease never expires.
+func (s *ServiceConfigClient) LeaseAlive(sequence string) (bool, error) {
+	cli, err := s.newClient()
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	key := s.GetKeyName(s.ServiceLB.ServerName, sequence)
+	serviceInfo, err := cli.Get(ctx, key)
 	if err != nil {
-		return fmt.Errorf("util.NewEtcd err: %v，etcdUrl: %v", err, s.ServiceLB.EtcdServerUrl)
+		return false, fmt.Errorf("cli.Get err: %v, key: %v", err, key)
+	}
+	if len(serviceInfo.Kvs) == 0 {
+		return false, nil
+	}
+	leaseID := clientv3.LeaseID(serviceInfo.Kvs[0].Lease)
+	if leaseID == clientv3.NoLease {
+		return true, nil
 	}
+	lease, err := cli.TimeToLive(ctx, leaseID)
+	if err != nil {
+		return false, fmt.Errorf("cli.TimeToLive err: %v, key: %v", err, key)
+	}
+	return lease.TTL > 0, nil
+}
 
+func (s *ServiceConfigClient) ClearConfig(sequence string) error {
+	cli, err := s.newClient()
+	if err != nil {
+		return err
+	}
 	key := s.GetKeyName(s.ServiceLB.ServerName, sequence)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
 	defer cancel()
 
-	_, err = client.NewKeysAPI(cli).Delete(ctx, key, nil)
+	resp, err := cli.Delete(ctx, key)
 	if err != nil {
-		if client.IsKeyNotFound(err) {
-			return ErrServiceConfigKeyNotExist
-		}
 		return fmt.Errorf("cli.Delete err: %v key: %v", err, key)
 	}
+	if resp.Deleted == 0 {
+		return ErrServiceConfigKeyNotExist
+	}
 
 	return nil
 }
 
+// WriteConfig writes the config without lease if the key does not exist, use Register for a service instance.
 func (s *ServiceConfigClient) WriteConfig(sequence string, c Config) error {
-	cli, err := util.NewEtcd(s.ServiceLB.EtcdServerUrl)
+	cli, err := s.newClient()
 	if err != nil {
-		return fmt.Errorf("util.NewEtcd err: %v，etcdUrl: %v", err, s.ServiceLB.EtcdServerUrl)
+		return err
 	}
-
 	key := s.GetKeyName(s.ServiceLB.ServerName, sequence)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
 	defer cancel()
 
 	jsonConfig, err := json.MarshalToString(&c)
 	if err != nil {
 		return fmt.Errorf("json.MarshalToString err: %v key: %v config: %+v", err, key, c)
 	}
-	_, err = client.NewKeysAPI(cli).Set(ctx, key, jsonConfig, &client.SetOptions{
-		PrevExist: client.PrevNoExist,
-	})
+	resp, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, jsonConfig)).
+		Commit()
 	if err != nil {
-		return fmt.Errorf("cli.Set err: %v key: %v values: %v", err, key, jsonConfig)
+		return fmt.Errorf("cli.Put err: %v key: %v values: %v", err, key, jsonConfig)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("cli.Put key: %v exist", key)
 	}
 
 	return nil
 }
 
+// Register writes the config under a lease of ttl and keeps the lease alive until the Registration is closed,
+// the config is written again under a new lease when the lease is lost, eg: etcd was unreachable longer than ttl.
+// the key of a killed process is removed by etcd when its lease expires.
+func (s *ServiceConfigClient) Register(sequence string, c Config, ttl time.Duration) (*Registration, error) {
+	cli, err := s.newClient()
+	if err != nil {
+		return nil, err
+	}
+	jsonConfig, err := json.MarshalToString(&c)
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalToString err: %v config: %+v", err, c)
+	}
+	return register(cli, s.GetKeyName(s.ServiceLB.ServerName, sequence), jsonConfig, ttl)
+}
+
+func register(cli leaseClient, key, value string, ttl time.Duration) (*Registration, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Registration{
+		cli:    cli,
+		key:    key,
+		value:  value,
+		ttl:    ttl,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	err := r.put()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	r.wg.Add(1)
+	go r.keepAlive()
+	return r, nil
+}
+
 func (s *ServiceConfigClient) ListConfigs() (map[string]*Config, error) {
-	return s.listConfigs("/")
+	return s.listConfigs(Service)
 }
 
 func (s *ServiceConfigClient) GetConfigs() (map[string]*Config, error) {
-	return s.listConfigs(s.GetKeyName(s.ServiceLB.ServerName))
+	return s.listConfigs(s.GetKeyName(s.ServiceLB.ServerName) + "/")
 }
 
+// Watch notices the changes of the service keys until ctx is done, the watch is resumed after an error.
 func (s *ServiceConfigClient) Watch(ctx context.Context) (<-chan struct{}, error) {
 	notice := make(chan struct{}, 1)
-	cli, err := util.NewEtcd(s.ServiceLB.EtcdServerUrl)
+	cli, err := s.newClient()
 	if err != nil {
-		return notice, fmt.Errorf("util.NewEtcd err: %v，etcdUrl: %v", err, s.ServiceLB.EtcdServerUrl)
+		return notice, err
 	}
 
-	kapi := client.NewKeysAPI(cli)
 	ctx, cancel := context.WithCancel(ctx)
-	watcher := kapi.Watcher(s.GetKeyName(s.ServiceLB.ServerName), &client.WatcherOptions{
-		AfterIndex: 0,
-		Recursive:  true,
-	})
+	key := s.GetKeyName(s.ServiceLB.ServerName) + "/"
+	// the shared client is closed on shutdown after the watch exits
+	watchDone := util.TrackEtcdV3Watch()
+	go func() {
+		select {
+		case <-vars.AppCloseCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 	go func() {
 		defer func() {
 			cancel()
 			close(notice)
+			watchDone()
 		}()
 		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-vars.AppCloseCh:
-				return
-			default:
-			}
-			resp, err := watcher.Next(ctx)
-			if err != nil {
-				time.Sleep(500 * time.Millisecond)
-				continue
-			}
-
-			if strings.ToLower(resp.Action) != "get" {
+			watchChan := cli.Watch(clientv3.WithRequireLeader(ctx), key, clientv3.WithPrefix())
+			for resp := range watchChan {
+				if resp.Err() != nil || len(resp.Events) == 0 {
+					continue
+				}
 				// 防止notice来不及被客户端消费
 				select {
 				case <-notice:
@@ -172,6 +265,19 @@ func (s *ServiceConfigClient) Watch(ctx context.Context) (<-chan struct{}, error
 				}
 				notice <- struct{}{}
 			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-vars.AppCloseCh:
+				return
+			case <-time.After(500 * time.Millisecond):
+			}
+			// the changes during the rewatch are picked by a resolve
+			select {
+			case <-notice:
+			default:
+			}
+			notice <- struct{}{}
 		}
 	}()
 
@@ -179,38 +285,143 @@ func (s *ServiceConfigClient) Watch(ctx context.Context) (<-chan struct{}, error
 }
 
 func (s *ServiceConfigClient) listConfigs(key string) (map[string]*Config, error) {
-	cli, err := util.NewEtcd(s.ServiceLB.EtcdServerUrl)
+	cli, err := s.newClient()
 	if err != nil {
-		return nil, fmt.Errorf("util.NewEtcd err: %v，etcdUrl: %v", err, s.ServiceLB.EtcdServerUrl)
+		return nil, err
 	}
-
-	kapi := client.NewKeysAPI(cli)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
 	defer cancel()
 
-	serviceInfos, err := kapi.Get(ctx, key, nil)
+	serviceInfos, err := cli.Get(ctx, key, clientv3.WithPrefix())
 	if err != nil {
-		if client.IsKeyNotFound(err) {
-			return nil, ErrServiceConfigKeyNotExist
-		}
 		return nil, fmt.Errorf("cli.Get err: %v key: %v", err, key)
 	}
+	if len(serviceInfos.Kvs) == 0 {
+		return nil, ErrServiceConfigKeyNotExist
+	}
 
 	configs := make(map[string]*Config)
-	for _, info := range serviceInfos.Node.Nodes {
+	for _, info := range serviceInfos.Kvs {
 		if len(info.Value) > 0 {
-			index := strings.Index(info.Key, Service)
+			index := strings.Index(string(info.Key), Service)
 			if index == 0 {
 				config := &Config{}
-				err := json.Unmarshal(info.Value, config)
+				err := json.Unmarshal(string(info.Value), config)
 				if err != nil {
-					return nil, fmt.Errorf("json.UnmarshalByte err: %v values: %v", err, info.Value)
+					return nil, fmt.Errorf("json.UnmarshalByte err: %v values: %v", err, string(info.Value))
 				}
 
-				configs[info.Key] = config
+				configs[string(info.Key)] = config
 			}
 		}
 	}
 
 	return configs, nil
 }
+
+// leaseClient is the part of *clientv3.Client used by Registration.
+type leaseClient interface {
+	Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error)
+	Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error)
+	KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error)
+	Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error)
+}
+
+// Registration is a service config kept alive by a lease.
+type Registration struct {
+	cli    leaseClient
+	key    string
+	value  string
+	ttl    time.Duration
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mutex   sync.Mutex
+	leaseID clientv3.LeaseID
+}
+
+// put writes the config under a new lease.
+func (r *Registration) put() error {
+	ctx, cancel := context.WithTimeout(r.ctx, requestTimeout)
+	defer cancel()
+	ttl := int64(r.ttl / time.Second)
+	if ttl <= 0 {
+		ttl = 1
+	}
+	lease, err := r.cli.Grant(ctx, ttl)
+	if err != nil {
+		return fmt.Errorf("cli.Grant err: %v key: %v", err, r.key)
+	}
+	_, err = r.cli.Put(ctx, r.key, r.value, clientv3.WithLease(lease.ID))
+	if err != nil {
+		return fmt.Errorf("cli.Put err: %v key: %v values: %v", err, r.key, r.value)
+	}
+	r.mutex.Lock()
+	r.leaseID = lease.ID
+	r.mutex.Unlock()
+	return nil
+}
+
+// keepAlive keeps the lease alive, and registers again with backoff when the lease is lost.
+func (r *Registration) keepAlive() {
+	defer r.wg.Done()
+	for {
+		r.mutex.Lock()
+		leaseID := r.leaseID
+		r.mutex.Unlock()
+		ch, err := r.cli.KeepAlive(r.ctx, leaseID)
+		if err == nil {
+			// closed when the lease expired or the keepalive failed
+			for range ch {
+			}
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+		logErrf("etcd lease of key %v lost, register again", r.key)
+
+		backoff := minRegisterBackoff
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			err = r.put()
+			if err == nil {
+				logging.Infof("etcd key %v registered again\n", r.key)
+				break
+			}
+			logErrf("etcd register key %v again err: %v", r.key, err)
+			backoff *= 2
+			if backoff > maxRegisterBackoff {
+				backoff = maxRegisterBackoff
+			}
+		}
+	}
+}
+
+// Close stops the keepalive and revokes the lease, so the config is deleted at once.
+func (r *Registration) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	r.mutex.Lock()
+	leaseID := r.leaseID
+	r.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := r.cli.Revoke(ctx, leaseID)
+	if err != nil {
+		return fmt.Errorf("cli.Revoke err: %v key: %v", err, r.key)
+	}
+	return nil
+}
+
+func logErrf(format string, v ...interface{}) {
+	if vars.FrameworkLogger != nil {
+		vars.FrameworkLogger.Errorf(emptyCtx, format, v...)
+	} else {
+		logging.Errf(format+"\n", v...)
+	}
+}
@@ -0,0 +1,127 @@
+package etcdconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/client/v3"
+)
+
+// fakeLeaseClient grants increasing lease ids, the lease of a keepalive is lost by closing its channel.
+type fakeLeaseClient struct {
+	mutex      sync.Mutex
+	leaseID    clientv3.LeaseID
+	grantErrs  int // the next grants failing
+	puts       int
+	revoked    []clientv3.LeaseID
+	keepAlives chan clientv3.LeaseID
+	lost       map[clientv3.LeaseID]func()
+}
+
+func newFakeLeaseClient() *fakeLeaseClient {
+	return &fakeLeaseClient{
+		keepAlives: make(chan clientv3.LeaseID, 10),
+		lost:       map[clientv3.LeaseID]func(){},
+	}
+}
+
+func (f *fakeLeaseClient) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.grantErrs > 0 {
+		f.grantErrs--
+		return nil, errors.New("etcd unavailable")
+	}
+	f.leaseID++
+	return &clientv3.LeaseGrantResponse{ID: f.leaseID, TTL: ttl}, nil
+}
+
+func (f *fakeLeaseClient) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	f.mutex.Lock()
+	f.puts++
+	f.mutex.Unlock()
+	return &clientv3.PutResponse{}, nil
+}
+
+func (f *fakeLeaseClient) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	ch := make(chan *clientv3.LeaseKeepAliveResponse)
+	var once sync.Once
+	lose := func() { once.Do(func() { close(ch) }) }
+	f.mutex.Lock()
+	f.lost[id] = lose
+	f.mutex.Unlock()
+	go func() {
+		<-ctx.Done()
+		lose()
+	}()
+	f.keepAlives <- id
+	return ch, nil
+}
+
+func (f *fakeLeaseClient) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	f.mutex.Lock()
+	f.revoked = append(f.revoked, id)
+	f.mutex.Unlock()
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+func (f *fakeLeaseClient) loseLease(id clientv3.LeaseID) {
+	f.mutex.Lock()
+	lose := f.lost[id]
+	f.mutex.Unlock()
+	lose()
+}
+
+func waitKeepAlive(t *testing.T, f *fakeLeaseClient) clientv3.LeaseID {
+	t.Helper()
+	select {
+	case id := <-f.keepAlives:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatal("keepalive not started")
+		return 0
+	}
+}
+
+func TestRegistration_RegisterAgainAfterLeaseLost(t *testing.T) {
+	f := newFakeLeaseClient()
+	r, err := register(f, "/kelvins-service.user.cluster/167772161_58001", "{}", 10*time.Second)
+	if err != nil {
+		t.Fatalf("register err: %v", err)
+	}
+	if id := waitKeepAlive(t, f); id != 1 {
+		t.Fatalf("keepalive lease %v, expect 1", id)
+	}
+
+	// the first register again fails, the second is made after the backoff
+	f.mutex.Lock()
+	f.grantErrs = 1
+	f.mutex.Unlock()
+	f.loseLease(1)
+	if id := waitKeepAlive(t, f); id != 2 {
+		t.Fatalf("keepalive lease %v after lost, expect 2", id)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("close err: %v", err)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.puts != 2 {
+		t.Errorf("puts %d, expect 2", f.puts)
+	}
+	if len(f.revoked) != 1 || f.revoked[0] != 2 {
+		t.Errorf("revoked %v, expect [2]", f.revoked)
+	}
+}
+
+func TestRegistration_PutErr(t *testing.T) {
+	f := newFakeLeaseClient()
+	f.grantErrs = 1
+	if _, err := register(f, "/kelvins-service.user.cluster/167772161_58001", "{}", time.Second); err == nil {
+		t.Fatal("expect register err when the lease can not be granted")
+	}
+}
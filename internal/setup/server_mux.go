@@ -1,12 +1,15 @@
 package setup
 
 import (
+	"gitee.com/kelvins-io/kelvins/internal/logging"
 	"gitee.com/kelvins-io/kelvins/internal/metrics_mux"
+	"gitee.com/kelvins-io/kelvins/util/health"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"net/http"
+	"net/url"
 )
 
-// NewServerMux ...
+// NewServerMux returns a mux serving the debug handlers if debug.
 func NewServerMux(debug bool) *http.ServeMux {
 	mux := http.NewServeMux()
 	if !debug {
@@ -18,6 +21,39 @@ func NewServerMux(debug bool) *http.ServeMux {
 	return mux
 }
 
+// RegisterHealthHandlers registers the liveness and readiness handlers on the app mux after the app routes,
+// a path registered by the app or - is skipped.
+func RegisterHealthHandlers(mux *http.ServeMux, livenessPath, readinessPath string) {
+	registerIfUnused(mux, livenessPath, health.LivenessHandler)
+	registerIfUnused(mux, readinessPath, health.ReadinessHandler)
+}
+
+func registerIfUnused(mux *http.ServeMux, path string, handler http.HandlerFunc) {
+	if path == "" || path == "-" {
+		return
+	}
+	_, pattern := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}})
+	if pattern == path {
+		logging.Infof("health handler %s is skipped, the path is used by the app\n", path)
+		return
+	}
+	mux.HandleFunc(path, handler)
+}
+
+// NewAdminServerMux returns the mux of the admin server serving /healthz /readyz /metrics /debug/vars /debug/config,
+// and /debug/pprof if pprof.
+func NewAdminServerMux(pprof bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	health.RegisterHandlers(mux)
+	mux = metrics_mux.GetElasticMux(mux)
+	mux = metrics_mux.GetPrometheusMux(mux)
+	mux = metrics_mux.GetConfigMux(mux)
+	if pprof {
+		mux = metrics_mux.GetPProfMux(mux)
+	}
+	return mux
+}
+
 // NewGatewayServerMux ...
 func NewGatewayServerMux(gateway *runtime.ServeMux, debug bool) *http.ServeMux {
 	mux := NewServerMux(debug)
@@ -1,17 +1,39 @@
 package util
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/etcd-io/etcd/client"
+	"go.etcd.io/etcd/client/v3"
 )
 
-func NewEtcd(urls string) (client.Client, error) {
-	cli, err := client.New(client.Config{
-		Endpoints:               strings.Split(urls, ","),
-		Transport:               client.DefaultTransport,
-		HeaderTimeoutPerRequest: 10 * time.Second,
+var ErrEtcdV3Closed = errors.New("etcd client is closed")
+
+var (
+	etcdV3Mutex    sync.Mutex
+	etcdV3Clients  = map[string]*clientv3.Client{} // urls => client
+	etcdV3Closed   bool
+	etcdV3Watchers sync.WaitGroup
+)
+
+// NewEtcdV3 returns a new client of the comma separated urls, an error is returned when urls has no endpoint.
+func NewEtcdV3(urls string) (*clientv3.Client, error) {
+	var endpoints []string
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			endpoints = append(endpoints, url)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("etcd urls %q has no endpoint", urls)
+	}
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 10 * time.Second,
 	})
 	if err != nil {
 		return nil, err
@@ -19,3 +41,58 @@ func NewEtcd(urls string) (client.Client, error) {
 
 	return cli, nil
 }
+
+// GetEtcdV3 returns the client of urls shared in the process, it reconnects by itself so the caller must not close it.
+func GetEtcdV3(urls string) (*clientv3.Client, error) {
+	etcdV3Mutex.Lock()
+	defer etcdV3Mutex.Unlock()
+	if etcdV3Closed {
+		return nil, ErrEtcdV3Closed
+	}
+	if cli, ok := etcdV3Clients[urls]; ok {
+		return cli, nil
+	}
+	cli, err := NewEtcdV3(urls)
+	if err != nil {
+		return nil, err
+	}
+	etcdV3Clients[urls] = cli
+	return cli, nil
+}
+
+// TrackEtcdV3Watch tracks a goroutine watching with a shared client, the returned func must be called when it exits.
+// the watcher must stop on the app close notice, CloseEtcdV3 waits for it before closing the clients.
+func TrackEtcdV3Watch() (done func()) {
+	etcdV3Watchers.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(etcdV3Watchers.Done)
+	}
+}
+
+// CloseEtcdV3 waits for the tracked watchers to exit until ctx is done, then closes the shared clients,
+// a later GetEtcdV3 returns ErrEtcdV3Closed.
+func CloseEtcdV3(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		etcdV3Watchers.Wait()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = fmt.Errorf("etcd watchers still running: %v", ctx.Err())
+	}
+
+	etcdV3Mutex.Lock()
+	defer etcdV3Mutex.Unlock()
+	etcdV3Closed = true
+	for urls, cli := range etcdV3Clients {
+		if e := cli.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(etcdV3Clients, urls)
+	}
+	return err
+}
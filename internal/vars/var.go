@@ -1,6 +1,11 @@
 package vars
 
-import "gitee.com/kelvins-io/common/log"
+import (
+	"context"
+	"time"
+
+	"gitee.com/kelvins-io/common/log"
+)
 
 // Version is internal vars fork root path vars.go
 var Version = "1.5.x"
@@ -20,8 +25,14 @@ var BusinessLogger log.LoggerContextIface
 // AppCloseCh is a internal vars for app close notice
 var AppCloseCh chan struct{}
 
+// AppContext is a internal vars for the root context of app, AppCancel is called when app shutdown
+var AppContext, AppCancel = context.WithCancel(context.Background())
+
 // ServiceIp is current service ip addr
 var ServiceIp string
 
 // ServicePort is current service port
 var ServicePort string
+
+// AppStartTime is the time the app process started
+var AppStartTime = time.Now()
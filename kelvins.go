@@ -13,21 +13,24 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	"net/http"
+	"time"
 )
 
 const (
-	AppTypeGrpc  = 1
-	AppTypeCron  = 2
-	AppTypeQueue = 3
-	AppTypeHttp  = 4
+	AppTypeGrpc   = 1
+	AppTypeCron   = 2
+	AppTypeQueue  = 3
+	AppTypeHttp   = 4
+	AppTypeWorker = 5
 )
 
 var (
 	AppTypeText = map[int32]string{
-		AppTypeGrpc:  "gRPC",
-		AppTypeCron:  "Cron",
-		AppTypeQueue: "Queue",
-		AppTypeHttp:  "Http",
+		AppTypeGrpc:   "gRPC",
+		AppTypeCron:   "Cron",
+		AppTypeQueue:  "Queue",
+		AppTypeHttp:   "Http",
+		AppTypeWorker: "Worker",
 	}
 )
 
@@ -65,6 +68,7 @@ type GRPCApplication struct {
 	RegisterHttpRoute        func(*http.ServeMux) error
 	RegisterEventProducer    func(event.ProducerIface) error
 	RegisterEventHandler     func(event.EventServerIface) error
+	RegisterEventHandlerCtx  func(ctx context.Context, server event.EventServerIface) error // handlers get contexts cancelled on shutdown, used if RegisterEventHandler is nil
 }
 
 type GRPCHealthServer struct {
@@ -78,38 +82,61 @@ func (a *GRPCHealthServer) AuthFuncOverride(ctx context.Context, fullMethodName
 
 // CronJob warps job define.
 type CronJob struct {
-	Name string // Job unique name
-	Spec string // Job specification
-	Job  func() // Job func
+	Name   string                    // Job unique name
+	Spec   string                    // Job specification
+	Job    func()                    // Job func
+	JobCtx func(ctx context.Context) // Job func with a context cancelled on shutdown, used if Job is nil
 }
 
 // CronApplication ...
 type CronApplication struct {
 	*Application
-	Cron                  *cron.Cron
-	GenCronJobs           func() []*CronJob
-	RegisterEventProducer func(event.ProducerIface) error
-	RegisterEventHandler  func(event.EventServerIface) error
+	Cron                    *cron.Cron
+	GenCronJobs             func() []*CronJob
+	RegisterEventProducer   func(event.ProducerIface) error
+	RegisterEventHandler    func(event.EventServerIface) error
+	RegisterEventHandlerCtx func(ctx context.Context, server event.EventServerIface) error // handlers get contexts cancelled on shutdown, used if RegisterEventHandler is nil
 }
 
 // QueueApplication ...
 type QueueApplication struct {
 	*Application
-	QueueServerToWorker   map[*queue.MachineryQueue][]*machinery.Worker
-	GetNamedTaskFuncs     func() map[string]interface{}
-	RegisterEventProducer func(event.ProducerIface) error
-	RegisterEventHandler  func(event.EventServerIface) error
+	QueueServerToWorker     map[*queue.MachineryQueue][]*machinery.Worker
+	GetNamedTaskFuncs       func() map[string]interface{}
+	RegisterEventProducer   func(event.ProducerIface) error
+	RegisterEventHandler    func(event.EventServerIface) error
+	RegisterEventHandlerCtx func(ctx context.Context, server event.EventServerIface) error // handlers get contexts cancelled on shutdown, used if RegisterEventHandler is nil
+}
+
+// WorkerLoop is a long-running background loop, eg: tailing a binlog or polling an api.
+type WorkerLoop struct {
+	Name        string                          // Loop unique name
+	Concurrency int                             // goroutines running Loop, default 1
+	Loop        func(ctx context.Context) error // runs until ctx is done, an error or panic restarts it with backoff
+}
+
+// WorkerApplication ...
+type WorkerApplication struct {
+	*Application
+	MaxConcurrency          int           // max goroutines of all loops, 0 means no limit
+	MinBackoff              time.Duration // restart backoff of a failed loop, default 1s, doubled up to MaxBackoff
+	MaxBackoff              time.Duration // default 30s
+	GenWorkerLoops          func() []*WorkerLoop
+	RegisterEventProducer   func(event.ProducerIface) error
+	RegisterEventHandler    func(event.EventServerIface) error
+	RegisterEventHandlerCtx func(ctx context.Context, server event.EventServerIface) error // handlers get contexts cancelled on shutdown, used if RegisterEventHandler is nil
 }
 
 // HTTPApplication ...
 type HTTPApplication struct {
 	*Application
-	Port                  int64
-	TlsConfig             *tls.Config
-	Mux                   *http.ServeMux
-	HttpServer            *http.Server
-	RegisterHttpRoute     func(*http.ServeMux) error
-	RegisterHttpGinRoute  func(*gin.Engine) // is not nil will over RegisterHttpGinRoute
-	RegisterEventProducer func(event.ProducerIface) error
-	RegisterEventHandler  func(event.EventServerIface) error
+	Port                    int64
+	TlsConfig               *tls.Config
+	Mux                     *http.ServeMux
+	HttpServer              *http.Server
+	RegisterHttpRoute       func(*http.ServeMux) error
+	RegisterHttpGinRoute    func(*gin.Engine) // is not nil will over RegisterHttpGinRoute
+	RegisterEventProducer   func(event.ProducerIface) error
+	RegisterEventHandler    func(event.EventServerIface) error
+	RegisterEventHandlerCtx func(ctx context.Context, server event.EventServerIface) error // handlers get contexts cancelled on shutdown, used if RegisterEventHandler is nil
 }
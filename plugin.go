@@ -0,0 +1,57 @@
+package kelvins
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Plugin is a framework extension, eg: an internal integration shipped as a package.
+// the framework maps ConfigSection, then calls Init and Start in dependency order after the resources are inited,
+// registers Health as a readiness check, and calls Stop in the reverse order on shutdown.
+type Plugin interface {
+	Name() string
+	// ConfigSection returns the section and the pointer to the setting struct it is mapped to before Init,
+	// the section is empty if the plugin has no config, an absent section leaves v unchanged.
+	ConfigSection() (section string, v interface{})
+	Init(ctx context.Context) error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	// Health returns nil if the plugin is healthy
+	Health(ctx context.Context) error
+}
+
+// PluginDependencies is implemented by a plugin which must be inited after other plugins.
+type PluginDependencies interface {
+	// Dependencies returns the names of the plugins inited before
+	Dependencies() []string
+}
+
+var (
+	pluginsMutex sync.Mutex
+	plugins      []Plugin
+)
+
+// RegisterPlugin registers plugin, it should be called before Run*Application eg: in the init of the plugin package.
+func RegisterPlugin(plugin Plugin) {
+	if plugin == nil || plugin.Name() == "" {
+		panic("plugin is nil or Name is empty")
+	}
+	pluginsMutex.Lock()
+	defer pluginsMutex.Unlock()
+	for _, p := range plugins {
+		if p.Name() == plugin.Name() {
+			panic(fmt.Sprintf("plugin %q is registered more than once", plugin.Name()))
+		}
+	}
+	plugins = append(plugins, plugin)
+}
+
+// Plugins returns the plugins in register order.
+func Plugins() []Plugin {
+	pluginsMutex.Lock()
+	defer pluginsMutex.Unlock()
+	result := make([]Plugin, len(plugins))
+	copy(result, plugins)
+	return result
+}
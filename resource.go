@@ -0,0 +1,47 @@
+package kelvins
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Resource is a client whose lifecycle is managed by the framework, eg: mysql redis elasticsearch kafka.
+// Init is called after the config is loaded, Check is run by the readiness checks,
+// and the resources are closed in the reverse order of Init on shutdown.
+type Resource struct {
+	Name  string                          // unique name, used as the health check name
+	Kind  string                          // eg: mysql redis, used as the metrics label
+	Init  func() error                    // creates the client, nil means the client is created already
+	Check func(ctx context.Context) error // nil means no health check
+	Close func(ctx context.Context) error // ctx expires at the shutdown deadline, nil means nothing to close
+}
+
+var (
+	resourcesMutex sync.Mutex
+	resources      []Resource
+)
+
+// RegisterResource registers resource, it should be called before Run*Application or in LoadConfig, SetupVars.
+func RegisterResource(resource Resource) {
+	if resource.Name == "" {
+		panic("resource Name is empty")
+	}
+	resourcesMutex.Lock()
+	defer resourcesMutex.Unlock()
+	for _, r := range resources {
+		if r.Name == resource.Name {
+			panic(fmt.Sprintf("resource %q is registered more than once", resource.Name))
+		}
+	}
+	resources = append(resources, resource)
+}
+
+// Resources returns the resources in register order.
+func Resources() []Resource {
+	resourcesMutex.Lock()
+	defer resourcesMutex.Unlock()
+	result := make([]Resource, len(resources))
+	copy(result, resources)
+	return result
+}
@@ -3,10 +3,7 @@ package client_conn
 import (
 	"context"
 	"fmt"
-	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
-	"gitee.com/kelvins-io/kelvins/internal/service/slb"
-	"gitee.com/kelvins-io/kelvins/internal/service/slb/etcdconfig"
 	"gitee.com/kelvins-io/kelvins/internal/vars"
 	"gitee.com/kelvins-io/kelvins/util/grpc_interceptor"
 	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -104,24 +101,17 @@ func (c *ConnClient) GetConn(ctx context.Context, opts ...grpc.DialOption) (*grp
 
 // GetEndpoints the returned endpoint list may have invalid nodes
 func (c *ConnClient) GetEndpoints(ctx context.Context) (endpoints []string, err error) {
-	etcdServerUrls := config.GetEtcdV3ServerURLs()
-	serviceLB := slb.NewService(etcdServerUrls, c.ServerName)
-	serviceConfigClient := etcdconfig.NewServiceConfigClient(serviceLB)
-	serviceConfigs, err := serviceConfigClient.GetConfigs()
+	instances, err := discoveryRegistry().List(ctx, c.ServerName)
 	if err != nil {
 		if vars.FrameworkLogger != nil {
-			vars.FrameworkLogger.Errorf(ctx, "etcd GetConfig(%v) err %v", c.ServerName, err)
+			vars.FrameworkLogger.Errorf(ctx, "registry List(%v) err %v", c.ServerName, err)
 		} else {
-			logging.Errf("etcd GetConfig(%v) err %v\n", c.ServerName, err)
+			logging.Errf("registry List(%v) err %v\n", c.ServerName, err)
 		}
 		return
 	}
-	for _, value := range serviceConfigs {
-		if value.ServiceIP != "" {
-			endpoints = append(endpoints, fmt.Sprintf("%v:%v", value.ServiceIP, value.ServicePort))
-		} else {
-			endpoints = append(endpoints, fmt.Sprintf("%v:%v", c.ServerName, value.ServicePort))
-		}
+	for _, ins := range instances {
+		endpoints = append(endpoints, ins.Addr())
 	}
 	return
 }
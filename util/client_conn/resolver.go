@@ -6,11 +6,11 @@ import (
 	"gitee.com/kelvins-io/kelvins"
 	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
-	"gitee.com/kelvins-io/kelvins/internal/service/slb"
-	"gitee.com/kelvins-io/kelvins/internal/service/slb/etcdconfig"
 	"gitee.com/kelvins-io/kelvins/internal/vars"
+	"gitee.com/kelvins-io/kelvins/util/registry"
 	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/resolver"
+	"sync"
 	"time"
 )
 
@@ -32,7 +32,7 @@ func (*kelvinsResolverBuilder) Build(target resolver.Target, cc resolver.ClientC
 	}
 
 	go r.watcher()
-	go r.listenEtcd()
+	go r.listenRegistry()
 
 	r.ResolveNow(resolver.ResolveNowOptions{})
 
@@ -81,44 +81,36 @@ var emptyCtx = context.Background()
 
 func (r *kelvinsResolver) resolverServiceConfig() {
 	serviceName := r.target.Endpoint
-	etcdServerUrls := config.GetEtcdV3ServerURLs()
-	serviceLB := slb.NewService(etcdServerUrls, serviceName)
-	serviceConfigClient := etcdconfig.NewServiceConfigClient(serviceLB)
-	var serviceConfigs map[string]*etcdconfig.Config
+	var instances []registry.Instance
 	var err error
 	// 有限的重试
 	for i := 0; i < 3; i++ {
-		serviceConfigs, err = serviceConfigClient.GetConfigs()
+		instances, err = discoveryRegistry().List(r.ctx, serviceName)
 		if err == nil {
 			break
 		}
 	}
 	if err != nil {
-		r.cc.ReportError(fmt.Errorf("etcd GetConfig(%v) err: %v", serviceName, err))
+		r.cc.ReportError(fmt.Errorf("registry List(%v) err: %v", serviceName, err))
 		if vars.FrameworkLogger != nil {
-			vars.FrameworkLogger.Errorf(emptyCtx, "etcd GetConfig(%v) err: %v", serviceName, err)
+			vars.FrameworkLogger.Errorf(emptyCtx, "registry List(%v) err: %v", serviceName, err)
 		} else {
-			logging.Errf("etcd GetConfig(%v) err: %v\n", serviceName, err)
+			logging.Errf("registry List(%v) err: %v\n", serviceName, err)
 		}
 		return
 	}
 
-	if len(serviceConfigs) == 0 {
+	if len(instances) == 0 {
 		return
 	}
 
-	address := make([]resolver.Address, 0, len(serviceConfigs))
-	for _, value := range serviceConfigs {
-		var addr string
-		if value.ServiceIP != "" {
-			addr = fmt.Sprintf("%v:%v", value.ServiceIP, value.ServicePort)
-		} else {
-			addr = fmt.Sprintf("%v:%v", serviceName, value.ServicePort)
-		}
-		// 可以在服务启动时注入机器info，然后在这里把机器info发给gRPC用于balance判断
+	address := make([]resolver.Address, 0, len(instances))
+	for _, ins := range instances {
+		addr := ins.Addr()
+		// 注册时注入的实例信息（版本，zone，权重，标签等）发给gRPC用于balance判断
 		address = append(address, resolver.Address{
 			Addr:       addr,
-			Attributes: attributes.New(kelvins.RPCMetadataServiceNode, addr),
+			Attributes: attributes.New(kelvins.RPCMetadataServiceNode, addr, instanceKey{}, ins),
 		})
 	}
 	if len(address) > 0 {
@@ -126,6 +118,17 @@ func (r *kelvinsResolver) resolverServiceConfig() {
 	}
 }
 
+type instanceKey struct{}
+
+// GetAddressInstance returns the registered instance of a resolved address, eg: in a custom balancer.
+func GetAddressInstance(addr resolver.Address) (registry.Instance, bool) {
+	if addr.Attributes == nil {
+		return registry.Instance{}, false
+	}
+	ins, ok := addr.Attributes.Value(instanceKey{}).(registry.Instance)
+	return ins, ok
+}
+
 func (r *kelvinsResolver) ResolveNow(o resolver.ResolveNowOptions) {
 	// 防止rn未来得及消费
 	select {
@@ -141,12 +144,25 @@ func (r *kelvinsResolver) ResolveNow(o resolver.ResolveNowOptions) {
 
 func (r *kelvinsResolver) Close() { r.cancel() }
 
-func (r *kelvinsResolver) listenEtcd() {
+var (
+	fallbackRegistryOnce sync.Once
+	fallbackRegistry     registry.Registry
+)
+
+// discoveryRegistry returns the registry set by the application, or the etcd registry of env ETCDV3_SERVER_URLS.
+func discoveryRegistry() registry.Registry {
+	if r := registry.Default(); r != nil {
+		return r
+	}
+	fallbackRegistryOnce.Do(func() {
+		fallbackRegistry = registry.NewCache(registry.NewEtcd(config.GetEtcdV3ServerURLs(), 0), "")
+	})
+	return fallbackRegistry
+}
+
+func (r *kelvinsResolver) listenRegistry() {
 	serviceName := r.target.Endpoint
-	etcdServerUrls := config.GetEtcdV3ServerURLs()
-	serviceLB := slb.NewService(etcdServerUrls, serviceName)
-	serviceConfigClient := etcdconfig.NewServiceConfigClient(serviceLB)
-	notice, err := serviceConfigClient.Watch(r.ctx)
+	notice, err := discoveryRegistry().Watch(r.ctx, serviceName)
 	if err != nil {
 		return
 	}
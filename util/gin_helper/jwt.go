@@ -1,7 +1,8 @@
 package gin_helper
 
 import (
-	"gitee.com/kelvins-io/kelvins"
+	"gitee.com/kelvins-io/kelvins/config/setting"
+	"gitee.com/kelvins-io/kelvins/internal/config"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"net/http"
@@ -48,13 +49,14 @@ type Claims struct {
 }
 
 func GenerateToken(username string, uid int) (string, error) {
+	jwtSetting := currentJwtSetting()
 	var expire = jwtExpireTime
-	if kelvins.JwtSetting != nil && kelvins.JwtSetting.TokenExpireSecond > 0 {
-		expire = time.Duration(kelvins.JwtSetting.TokenExpireSecond) * time.Second
+	if jwtSetting != nil && jwtSetting.TokenExpireSecond > 0 {
+		expire = time.Duration(jwtSetting.TokenExpireSecond) * time.Second
 	}
 	var secret = jwtSecret
-	if kelvins.JwtSetting != nil && kelvins.JwtSetting.Secret != "" {
-		secret = kelvins.JwtSetting.Secret
+	if jwtSetting != nil && jwtSetting.Secret != "" {
+		secret = jwtSetting.Secret
 	}
 	nowTime := time.Now()
 	expireTime := nowTime.Add(expire)
@@ -74,8 +76,8 @@ func GenerateToken(username string, uid int) (string, error) {
 
 func ParseToken(token string) (*Claims, error) {
 	var secret = jwtSecret
-	if kelvins.JwtSetting != nil && kelvins.JwtSetting.Secret != "" {
-		secret = kelvins.JwtSetting.Secret
+	if jwtSetting := currentJwtSetting(); jwtSetting != nil && jwtSetting.Secret != "" {
+		secret = jwtSetting.Secret
 	}
 	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (i interface{}, err error) {
 		return []byte(secret), nil
@@ -87,3 +89,9 @@ func ParseToken(token string) (*Claims, error) {
 	}
 	return nil, err
 }
+
+// currentJwtSetting returns kelvins-jwt of the last config reload, it is read by the requests concurrently with reloads.
+func currentJwtSetting() *setting.JwtSettingS {
+	jwtSetting, _ := config.Setting(config.SectionJwt).(*setting.JwtSettingS)
+	return jwtSetting
+}
@@ -11,6 +11,11 @@ func RateLimit(maxConcurrent int) gin.HandlerFunc {
 	if maxConcurrent > 0 {
 		limiter = middleware.NewKelvinsRateLimit(maxConcurrent)
 	}
+	return RateLimiter(limiter)
+}
+
+// RateLimiter uses limiter to reject requests, pass a middleware.AdjustableLimiter to change the limit at runtime
+func RateLimiter(limiter middleware.Limiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if limiter != nil {
 			if limiter.Limit() {
@@ -108,6 +108,7 @@ type Pool struct {
 	JobQueue   chan Job
 	dispatcher *dispatcher
 	wg         sync.WaitGroup
+	ctx        context.Context
 }
 
 // NewPool Will make pool of gorouting workers.
@@ -116,6 +117,11 @@ type Pool struct {
 //
 // Returned object contains JobQueue reference, which you can use to send job to pool.
 func NewPool(numWorkers int, jobQueueLen int) *Pool {
+	return NewPoolWithContext(context.Background(), numWorkers, jobQueueLen)
+}
+
+// NewPoolWithContext is NewPool, the jobs sent by SendContextJob run with a context derived from ctx.
+func NewPoolWithContext(ctx context.Context, numWorkers int, jobQueueLen int) *Pool {
 	if numWorkers <= 0 {
 		numWorkers = 2
 	}
@@ -128,6 +134,7 @@ func NewPool(numWorkers int, jobQueueLen int) *Pool {
 	pool := &Pool{
 		JobQueue:   jobQueue,
 		dispatcher: newDispatcher(workerPool, jobQueue),
+		ctx:        ctx,
 	}
 
 	return pool
@@ -140,6 +147,24 @@ func (p *Pool) wrapJob(job func()) func() {
 	}
 }
 
+func (p *Pool) contextJob(job func(ctx context.Context)) func() {
+	return func() {
+		ctx, cancel := context.WithCancel(p.ctx)
+		defer cancel()
+		job(ctx)
+	}
+}
+
+// SendContextJob is SendJob, job receives a context derived from the pool context.
+func (p *Pool) SendContextJob(job func(ctx context.Context)) {
+	p.SendJob(p.contextJob(job))
+}
+
+// SendContextJobWithTimeout is SendJobWithTimeout, job receives a context derived from the pool context.
+func (p *Pool) SendContextJobWithTimeout(job func(ctx context.Context), t time.Duration) bool {
+	return p.SendJobWithTimeout(p.contextJob(job), t)
+}
+
 func (p *Pool) SendJobWithTimeout(job func(), t time.Duration) bool {
 	select {
 	case <-time.After(t):
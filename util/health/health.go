@@ -0,0 +1,245 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+// DefaultTimeout is the timeout of a check without Timeout.
+const DefaultTimeout = 3 * time.Second
+
+// Check is a named health check.
+// the readiness checks are run by /readyz and mirrored into the grpc health service,
+// the liveness checks are run by /healthz too, a failed liveness check means the process should be restarted.
+type Check struct {
+	Name     string
+	Service  string        // grpc health service name eg: pkg.Service, empty means the whole server
+	Liveness bool          // true means the check is run by /healthz and /readyz
+	Timeout  time.Duration // DefaultTimeout if 0
+	Func     func(ctx context.Context) error
+}
+
+// Result is the result of a check.
+type Result struct {
+	Name     string `json:"name"`
+	Service  string `json:"service,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the results of the checks, Status is DOWN if one check is down.
+type Report struct {
+	Status string   `json:"status"`
+	Reason string   `json:"reason,omitempty"`
+	Checks []Result `json:"checks"`
+}
+
+var (
+	mutex    sync.RWMutex
+	checks   = map[string]Check{}
+	shutdown bool
+)
+
+// Register registers check, a check of the same name is replaced.
+func Register(check Check) {
+	if check.Name == "" || check.Func == nil {
+		panic("health check Name or Func is empty")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	checks[check.Name] = check
+}
+
+// Unregister removes the check of name.
+func Unregister(name string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(checks, name)
+}
+
+// SetShutdown marks the process shutting down, readiness is DOWN from then on.
+func SetShutdown() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	shutdown = true
+}
+
+// IsShutdown reports whether SetShutdown is called.
+func IsShutdown() bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return shutdown
+}
+
+// Names returns the sorted names of the checks.
+func Names() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Services returns the sorted service names of the checks, the whole server "" is always included.
+func Services() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	services := []string{""}
+	seen := map[string]bool{"": true}
+	for _, check := range checks {
+		if !seen[check.Service] {
+			seen[check.Service] = true
+			services = append(services, check.Service)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+func selectChecks(liveness bool) []Check {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	var result []Check
+	for _, check := range checks {
+		if liveness && !check.Liveness {
+			continue
+		}
+		result = append(result, check)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- check.Func(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := Result{
+		Name:     check.Name,
+		Service:  check.Service,
+		Status:   StatusUp,
+		Duration: time.Since(start).Truncate(time.Microsecond).String(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func report(ctx context.Context, checks []Check) Report {
+	r := Report{Status: StatusUp, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.Checks[i] = run(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range r.Checks {
+		if result.Status != StatusUp {
+			r.Status = StatusDown
+		}
+	}
+	return r
+}
+
+// Liveness runs the liveness checks.
+func Liveness(ctx context.Context) Report {
+	return report(ctx, selectChecks(true))
+}
+
+// Readiness runs every check, it is DOWN once the process is shutting down.
+func Readiness(ctx context.Context) Report {
+	r := report(ctx, selectChecks(false))
+	if IsShutdown() {
+		r.Status = StatusDown
+		r.Reason = "shutting down"
+	}
+	return r
+}
+
+// ServiceStatus returns whether service is serving in r,
+// the checks of the whole server count for every service.
+func (r Report) ServiceStatus(service string) bool {
+	if r.Reason != "" {
+		return false
+	}
+	for _, result := range r.Checks {
+		if result.Status == StatusUp {
+			continue
+		}
+		if service == "" || result.Service == "" || result.Service == service {
+			return false
+		}
+	}
+	return true
+}
+
+// LivenessHandler serves /healthz.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Liveness(r.Context()))
+}
+
+// ReadinessHandler serves /readyz.
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Readiness(r.Context()))
+}
+
+// RegisterHandlers registers /healthz and /readyz on mux.
+func RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", LivenessHandler)
+	mux.HandleFunc("/readyz", ReadinessHandler)
+}
+
+func writeReport(w http.ResponseWriter, r Report) {
+	code := http.StatusOK
+	if r.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	if r.Checks == nil {
+		r.Checks = []Result{}
+	}
+	body, _ := json.Marshal(r)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(body)
+}
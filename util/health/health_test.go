@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	Register(Check{Name: "db", Liveness: true, Func: func(ctx context.Context) error { return nil }})
+	Register(Check{Name: "user-api", Service: "pkg.User", Func: func(ctx context.Context) error { return errors.New("down") }})
+	Register(Check{Name: "slow", Service: "pkg.Order", Timeout: 10 * time.Millisecond, Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	defer func() {
+		Unregister("db")
+		Unregister("user-api")
+		Unregister("slow")
+	}()
+
+	if r := Liveness(context.Background()); r.Status != StatusUp || len(r.Checks) != 1 {
+		t.Fatalf("liveness = %+v", r)
+	}
+	r := Readiness(context.Background())
+	if r.Status != StatusDown || len(r.Checks) != 3 {
+		t.Fatalf("readiness = %+v", r)
+	}
+	for service, want := range map[string]bool{"": false, "pkg.User": false, "pkg.Order": false, "pkg.Other": true} {
+		if got := r.ServiceStatus(service); got != want {
+			t.Errorf("ServiceStatus(%q) = %v, want %v", service, got, want)
+		}
+	}
+	if services := Services(); len(services) != 3 || services[0] != "" {
+		t.Errorf("Services() = %v", services)
+	}
+
+	mux := http.NewServeMux()
+	RegisterHandlers(mux)
+	for path, code := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code {
+			t.Errorf("%s code = %d, want %d", path, w.Code, code)
+		}
+		var report Report
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Errorf("%s body %s: %v", path, w.Body.String(), err)
+		}
+	}
+}
@@ -23,34 +23,61 @@ type KProcess struct {
 // This shows how to use the upgrader
 // with the graceful shutdown facilities of net/http.
 func (k *KProcess) Listen(network, addr, pidFile string) (ln net.Listener, err error) {
-	k.pid = os.Getpid()
-	logging.Infof(fmt.Sprintf("exec process pid %d \n", k.pid))
-
-	k.processUp, err = tableflip.New(tableflip.Options{
-		UpgradeTimeout: 5 * time.Second,
-		PIDFile:        pidFile,
-	})
+	err = k.Open(pidFile)
 	if err != nil {
 		return nil, err
 	}
-	k.pidFile = pidFile
-
-	go k.signal(k.upgrade, k.stop)
 
 	// Listen must be called before Ready
 	if network != "" && addr != "" {
-		ln, err = k.processUp.Listen(network, addr)
+		ln, err = k.ListenAddr(network, addr)
 		if err != nil {
 			return nil, err
 		}
 	}
-	if err := k.processUp.Ready(); err != nil {
+	if err := k.Ready(); err != nil {
 		return nil, err
 	}
 
 	return ln, nil
 }
 
+// Open prepares the process upgrader, call ListenAddr for every listener then Ready.
+func (k *KProcess) Open(pidFile string) (err error) {
+	k.pid = os.Getpid()
+	logging.Infof(fmt.Sprintf("exec process pid %d \n", k.pid))
+
+	k.processUp, err = tableflip.New(tableflip.Options{
+		UpgradeTimeout: 5 * time.Second,
+		PIDFile:        pidFile,
+	})
+	if err != nil {
+		return err
+	}
+	k.pidFile = pidFile
+
+	go k.signal(k.upgrade, k.stop)
+	return nil
+}
+
+// ListenAddr listens addr, the listener is inherited by the new process on restart.
+func (k *KProcess) ListenAddr(network, addr string) (net.Listener, error) {
+	return k.processUp.Listen(network, addr)
+}
+
+// ListenFreePort listens a port picked by the system, the listener is kept by name instead of the address,
+// so the new process on restart inherits it and listens the same port.
+func (k *KProcess) ListenFreePort(network, name string) (net.Listener, error) {
+	return k.processUp.ListenWithCallback(network, name, func(network, _ string) (net.Listener, error) {
+		return net.Listen(network, ":0")
+	})
+}
+
+// Ready writes the pid file and signals the parent process on restart to exit.
+func (k *KProcess) Ready() error {
+	return k.processUp.Ready()
+}
+
 func (k *KProcess) stop() error {
 	if k.processUp != nil {
 		k.processUp.Stop()
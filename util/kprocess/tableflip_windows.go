@@ -20,6 +20,19 @@ type KProcess struct {
 // This shows how to use the upgrader
 // with the graceful shutdown facilities of net/http.
 func (k *KProcess) Listen(network, addr, pidFile string) (ln net.Listener, err error) {
+	err = k.Open(pidFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if network != "" && addr != "" {
+		return k.ListenAddr(network, addr)
+	}
+	return nil, nil
+}
+
+// Open prepares the process, call ListenAddr for every listener then Ready.
+func (k *KProcess) Open(pidFile string) error {
 	if k.ch == nil {
 		k.ch = make(chan struct{})
 	}
@@ -28,11 +41,22 @@ func (k *KProcess) Listen(network, addr, pidFile string) (ln net.Listener, err e
 	logging.Info("warning windows only support process shutdown ")
 
 	go k.signal(k.stop)
+	return nil
+}
 
-	if network != "" && addr != "" {
-		return net.Listen(network, addr)
-	}
-	return nil, nil
+// ListenAddr listens addr.
+func (k *KProcess) ListenAddr(network, addr string) (net.Listener, error) {
+	return net.Listen(network, addr)
+}
+
+// ListenFreePort listens a port picked by the system.
+func (k *KProcess) ListenFreePort(network, name string) (net.Listener, error) {
+	return net.Listen(network, ":0")
+}
+
+// Ready is a no-op on windows.
+func (k *KProcess) Ready() error {
+	return nil
 }
 
 func (k *KProcess) stop() error {
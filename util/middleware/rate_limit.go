@@ -5,73 +5,57 @@ import (
 	"sync/atomic"
 )
 
-func NewKelvinsRateLimit(maxConcurrent int) Limiter {
+func NewKelvinsRateLimit(maxConcurrent int) AdjustableLimiter {
 	limiter := &kelvinsRateLimit{}
-	if maxConcurrent > 0 {
-		limiter.maxConcurrent = maxConcurrent
-		limiter.tickets = make(chan struct{}, maxConcurrent+1)
-	}
+	limiter.SetMaxConcurrent(maxConcurrent)
 	return limiter
 }
 
 type kelvinsRateLimit struct {
-	maxConcurrent int
-	tickets       chan struct{}
-	ticketsState  int32
+	maxConcurrent int64
+	concurrent    int64
 }
 
 func (r *kelvinsRateLimit) Limit() bool {
+	// every request is counted, so the limit can be changed while requests are in flight
+	concurrent := atomic.AddInt64(&r.concurrent, 1)
+	maxConcurrent := atomic.LoadInt64(&r.maxConcurrent)
 	// no limit
-	if r.maxConcurrent == 0 {
-		return false
-	}
-	// take ticket
-	take := r.takeTicket()
-	if take {
+	if maxConcurrent <= 0 {
 		return false
 	}
-
-	return true
-}
-
-func (r *kelvinsRateLimit) takeTicket() bool {
-	if r.maxConcurrent == 0 {
+	select {
+	case <-kelvins.AppCloseCh:
+		atomic.AddInt64(&r.concurrent, -1)
 		return true
+	default:
 	}
-	if r.tickets == nil {
+	if concurrent > maxConcurrent {
+		atomic.AddInt64(&r.concurrent, -1)
 		return true
-	} else {
-		if atomic.LoadInt32(&r.ticketsState) == 1 {
-			return false
-		}
 	}
 
-	select {
-	case r.tickets <- struct{}{}:
-		return true
-	case <-kelvins.AppCloseCh:
-		atomic.StoreInt32(&r.ticketsState, 1)
-		close(r.tickets)
-		return false
-	default:
-		return false
-	}
+	return false
 }
 
 func (r *kelvinsRateLimit) ReturnTicket() {
-	if r.maxConcurrent == 0 {
-		return
-	}
-	if r.tickets == nil {
-		return
-	}
-	select {
-	case <-r.tickets:
-	default:
+	atomic.AddInt64(&r.concurrent, -1)
+}
+
+func (r *kelvinsRateLimit) SetMaxConcurrent(maxConcurrent int) {
+	if maxConcurrent < 0 {
+		maxConcurrent = 0
 	}
+	atomic.StoreInt64(&r.maxConcurrent, int64(maxConcurrent))
 }
 
 type Limiter interface {
 	Limit() bool
 	ReturnTicket()
 }
+
+// AdjustableLimiter is a Limiter whose max concurrent can be changed at runtime, 0 means no limit.
+type AdjustableLimiter interface {
+	Limiter
+	SetMaxConcurrent(maxConcurrent int)
+}
@@ -10,7 +10,7 @@ import (
 )
 
 type RPCRateLimitInterceptor struct {
-	limiter Limiter
+	limiter AdjustableLimiter
 }
 
 func NewRPCRateLimitInterceptor(maxConcurrent int) *RPCRateLimitInterceptor {
@@ -19,6 +19,11 @@ func NewRPCRateLimitInterceptor(maxConcurrent int) *RPCRateLimitInterceptor {
 	}
 }
 
+// SetMaxConcurrent changes the limit of the running interceptor, 0 means no limit.
+func (r *RPCRateLimitInterceptor) SetMaxConcurrent(maxConcurrent int) {
+	r.limiter.SetMaxConcurrent(maxConcurrent)
+}
+
 func (r *RPCRateLimitInterceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		if r.limiter.Limit() {
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// Cache keeps the last known instances of the services listed from a registry, refreshed by List and the Watch notices,
+// they are returned when the registry fails, so the clients keep routing during an outage, eg: etcd is unavailable.
+// the instances are persisted to a snapshot file if its path is set, the snapshot is loaded by NewCache to warm start.
+// the snapshot has the format of the file registry.
+type Cache struct {
+	Registry
+	path string
+
+	mutex     sync.RWMutex
+	instances map[string][]Instance // service => instances
+
+	saveMutex sync.Mutex
+}
+
+// NewCache returns the cache of r, snapshotPath is empty to keep the instances in memory only.
+func NewCache(r Registry, snapshotPath string) *Cache {
+	c := &Cache{
+		Registry:  r,
+		path:      snapshotPath,
+		instances: map[string][]Instance{},
+	}
+	_ = c.load()
+	return c
+}
+
+// List returns the cached instances if the registry fails and service has been listed before or is in the snapshot.
+func (c *Cache) List(ctx context.Context, service string) ([]Instance, error) {
+	result, err := c.Registry.List(ctx, service)
+	if err != nil {
+		c.mutex.RLock()
+		cached, ok := c.instances[service]
+		c.mutex.RUnlock()
+		if ok {
+			return append([]Instance(nil), cached...), nil
+		}
+		return nil, err
+	}
+	c.set(service, result)
+	return result, nil
+}
+
+// Watch refreshes the cache on the notices of the registry before passing them on.
+func (c *Cache) Watch(ctx context.Context, service string) (<-chan struct{}, error) {
+	notice, err := c.Registry.Watch(ctx, service)
+	if err != nil {
+		return notice, err
+	}
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		for range notice {
+			if result, err := c.Registry.List(ctx, service); err == nil {
+				c.set(service, result)
+			}
+			notify(out)
+		}
+	}()
+	return out, nil
+}
+
+func (c *Cache) set(service string, instances []Instance) {
+	c.mutex.Lock()
+	if cached, ok := c.instances[service]; ok && reflect.DeepEqual(cached, instances) {
+		c.mutex.Unlock()
+		return
+	}
+	c.instances[service] = append([]Instance(nil), instances...)
+	c.mutex.Unlock()
+	_ = c.save()
+}
+
+func (c *Cache) load() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	services := map[string][]Instance{}
+	err = json.Unmarshal(data, &services)
+	if err != nil {
+		return fmt.Errorf("registry snapshot(%v) json.Unmarshal err: %v", c.path, err)
+	}
+	c.mutex.Lock()
+	for service, instances := range services {
+		for i := range instances {
+			instances[i].Service = service
+		}
+		c.instances[service] = instances
+	}
+	c.mutex.Unlock()
+	return nil
+}
+
+// save writes the snapshot through a temp file, so a crash does not leave a broken snapshot.
+func (c *Cache) save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+	c.mutex.RLock()
+	data, err := json.MarshalIndent(c.instances, "", "  ")
+	c.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,185 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"gitee.com/kelvins-io/kelvins/internal/logging"
+	"gitee.com/kelvins-io/kelvins/internal/service/slb"
+	"gitee.com/kelvins-io/kelvins/internal/service/slb/etcdconfig"
+)
+
+// Etcd is the registry on etcd v3, a registered instance is kept alive by a lease of ttl.
+type Etcd struct {
+	urls string
+	ttl  time.Duration
+
+	mutex         sync.Mutex
+	registrations map[string]*etcdconfig.Registration // key => registration
+}
+
+// NewEtcd returns the etcd registry of the comma separated urls.
+func NewEtcd(urls string, ttl time.Duration) *Etcd {
+	return &Etcd{
+		urls:          urls,
+		ttl:           ttl,
+		registrations: map[string]*etcdconfig.Registration{},
+	}
+}
+
+// ErrEtcdURLsEmpty is returned when the etcd registry has no urls, eg: env ETCDV3_SERVER_URLS is not set.
+var ErrEtcdURLsEmpty = errors.New("etcd server urls is empty, set env ETCDV3_SERVER_URLS")
+
+func (e *Etcd) client(service string) *etcdconfig.ServiceConfigClient {
+	return etcdconfig.NewServiceConfigClient(slb.NewService(e.urls, service))
+}
+
+// Register replaces an existing key of the same address if it describes the same instance, eg: left by a crashed process
+// before its lease expired, or of the parent process on restart; or if its lease expired.
+// it fails if the key is kept alive by another instance, eg: two hosts advertise the same address.
+func (e *Etcd) Register(ctx context.Context, ins Instance) error {
+	if e.urls == "" {
+		return ErrEtcdURLsEmpty
+	}
+	client := e.client(ins.Service)
+	sequence := getServiceSequence(ins.IP, ins.Port)
+	key := client.GetKeyName(ins.Service, sequence)
+	serviceConfig, err := client.GetConfig(sequence)
+	if err != nil && err != etcdconfig.ErrServiceConfigKeyNotExist {
+		return fmt.Errorf("etcd GetConfig err: %v, key: %v", err, key)
+	}
+	if serviceConfig != nil && !sameInstance(serviceConfig, ins) {
+		alive, err := client.LeaseAlive(sequence)
+		if err != nil {
+			return fmt.Errorf("etcd LeaseAlive err: %v, key: %v", err, key)
+		}
+		if alive {
+			return fmt.Errorf("etcd key %v is registered by another instance(hostname %v, start time %v)", key, serviceConfig.Hostname, serviceConfig.StartTime)
+		}
+	}
+	if serviceConfig != nil {
+		logging.Infof("etcd key %v exists, replace the stale registration(start time %v)\n", key, serviceConfig.StartTime)
+	}
+
+	registration, err := client.Register(sequence, etcdconfig.Config{
+		ServiceVersion:   ins.Version,
+		ServicePort:      ins.Port,
+		ServiceIP:        ins.IP,
+		ServiceKind:      ins.Kind,
+		LastModified:     ins.LastModified,
+		Hostname:         ins.Hostname,
+		FrameworkVersion: ins.FrameworkVersion,
+		BuildCommit:      ins.BuildCommit,
+		Zone:             ins.Zone,
+		Region:           ins.Region,
+		Weight:           ins.Weight,
+		Tags:             ins.Tags,
+		Protocol:         ins.Protocol,
+		StartTime:        ins.StartTime,
+		Labels:           ins.Labels,
+	}, e.ttl)
+	if err != nil {
+		return fmt.Errorf("etcd Register err: %v, key: %v", err, key)
+	}
+	e.mutex.Lock()
+	e.registrations[key] = registration
+	e.mutex.Unlock()
+	return nil
+}
+
+// Deregister revokes the lease of an instance registered by Register, or deletes the key otherwise.
+// the key is not deleted if the revoke fails, it may have been replaced by the new process on restart.
+func (e *Etcd) Deregister(ctx context.Context, ins Instance) error {
+	client := e.client(ins.Service)
+	sequence := getServiceSequence(ins.IP, ins.Port)
+	key := client.GetKeyName(ins.Service, sequence)
+	e.mutex.Lock()
+	registration := e.registrations[key]
+	delete(e.registrations, key)
+	e.mutex.Unlock()
+	if registration != nil {
+		err := registration.Close()
+		if err != nil {
+			return fmt.Errorf("etcd revoke err: %v, key: %v", err, key)
+		}
+		return nil
+	}
+	err := client.ClearConfig(sequence)
+	if err != nil && err != etcdconfig.ErrServiceConfigKeyNotExist {
+		return fmt.Errorf("etcd ClearConfig err: %v, key: %v", err, key)
+	}
+	return nil
+}
+
+func (e *Etcd) List(ctx context.Context, service string) ([]Instance, error) {
+	if e.urls == "" {
+		return nil, ErrEtcdURLsEmpty
+	}
+	serviceConfigs, err := e.client(service).GetConfigs()
+	if err == etcdconfig.ErrServiceConfigKeyNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Instance, 0, len(serviceConfigs))
+	for _, c := range serviceConfigs {
+		result = append(result, Instance{
+			Service:          service,
+			Kind:             c.ServiceKind,
+			IP:               c.ServiceIP,
+			Port:             c.ServicePort,
+			Hostname:         c.Hostname,
+			Version:          c.ServiceVersion,
+			FrameworkVersion: c.FrameworkVersion,
+			BuildCommit:      c.BuildCommit,
+			Zone:             c.Zone,
+			Region:           c.Region,
+			Weight:           c.Weight,
+			Tags:             c.Tags,
+			Protocol:         c.Protocol,
+			StartTime:        c.StartTime,
+			Labels:           c.Labels,
+			LastModified:     c.LastModified,
+		})
+	}
+	return result, nil
+}
+
+func (e *Etcd) Watch(ctx context.Context, service string) (<-chan struct{}, error) {
+	if e.urls == "" {
+		return nil, ErrEtcdURLsEmpty
+	}
+	return e.client(service).Watch(ctx)
+}
+
+// sameInstance reports whether c is registered by the instance ins or by its earlier process on the same host,
+// the configs of the earlier versions have no hostname, they are taken as the same instance of the address.
+func sameInstance(c *etcdconfig.Config, ins Instance) bool {
+	if c.ServiceIP != ins.IP || c.ServicePort != ins.Port {
+		return false
+	}
+	return c.Hostname == "" || c.Hostname == ins.Hostname
+}
+
+// getServiceSequence returns the key of an instance, the ip as a number, ipv4 keys are kept from the earlier versions,
+// an advertised host name is used as it is.
+func getServiceSequence(ip, port string) (key string) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Sprintf("%v_%v", ip, port)
+	}
+	ret := big.NewInt(0)
+	if ip4 := parsed.To4(); ip4 != nil {
+		ret.SetBytes(ip4)
+	} else {
+		ret.SetBytes(parsed.To16())
+	}
+	key = fmt.Sprintf("%v_%v", ret.String(), port)
+	return
+}
@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"testing"
+
+	"gitee.com/kelvins-io/kelvins/internal/service/slb/etcdconfig"
+)
+
+func TestSameInstance(t *testing.T) {
+	ins := Instance{Service: "user", IP: "10.0.0.1", Port: "58001", Hostname: "host-a"}
+	cases := []struct {
+		name   string
+		config etcdconfig.Config
+		expect bool
+	}{
+		{"same host", etcdconfig.Config{ServiceIP: "10.0.0.1", ServicePort: "58001", Hostname: "host-a"}, true},
+		{"earlier version", etcdconfig.Config{ServiceIP: "10.0.0.1", ServicePort: "58001"}, true},
+		{"another host", etcdconfig.Config{ServiceIP: "10.0.0.1", ServicePort: "58001", Hostname: "host-b"}, false},
+		{"another port", etcdconfig.Config{ServiceIP: "10.0.0.1", ServicePort: "58002", Hostname: "host-a"}, false},
+	}
+	for _, c := range cases {
+		if got := sameInstance(&c.config, ins); got != c.expect {
+			t.Errorf("%s: sameInstance = %v, expect %v", c.name, got, c.expect)
+		}
+	}
+}
+
+func TestGetServiceSequence(t *testing.T) {
+	cases := []struct {
+		ip, port string
+		expect   string
+	}{
+		{"10.0.0.1", "1", "167772161_1"},
+		{"::ffff:10.0.0.1", "1", "167772161_1"},
+		{"fe80::1", "2", "338288524927261089654018896841347694593_2"},
+		{"svc.local", "3", "svc.local_3"},
+	}
+	for _, c := range cases {
+		if got := getServiceSequence(c.ip, c.port); got != c.expect {
+			t.Errorf("getServiceSequence(%v, %v) = %v, expect %v", c.ip, c.port, got, c.expect)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// DefaultFileWatchInterval is the interval a file registry checks the file for changes.
+const DefaultFileWatchInterval = 3 * time.Second
+
+// File is a static registry read from a json file mapping the service names to their instances, eg:
+//
+//	{"kelvins-template": [{"ip": "127.0.0.1", "port": "58001"}]}
+//
+// the file is maintained by hand, so Register and Deregister do nothing. it is meant for local development.
+type File struct {
+	path     string
+	interval time.Duration
+}
+
+// NewFile returns a file registry reading path, the file is checked every interval for Watch,
+// DefaultFileWatchInterval is used if interval is 0.
+func NewFile(path string, interval time.Duration) *File {
+	if interval <= 0 {
+		interval = DefaultFileWatchInterval
+	}
+	return &File{path: path, interval: interval}
+}
+
+func (f *File) Register(ctx context.Context, ins Instance) error {
+	return nil
+}
+
+func (f *File) Deregister(ctx context.Context, ins Instance) error {
+	return nil
+}
+
+func (f *File) List(ctx context.Context, service string) ([]Instance, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("read registry file(%v) err: %v", f.path, err)
+	}
+	services := map[string][]Instance{}
+	err = json.Unmarshal(data, &services)
+	if err != nil {
+		return nil, fmt.Errorf("registry file(%v) json.Unmarshal err: %v", f.path, err)
+	}
+	result := services[service]
+	for i := range result {
+		result[i].Service = service
+	}
+	return result, nil
+}
+
+// Watch notices when the modification time or the size of the file changes.
+func (f *File) Watch(ctx context.Context, service string) (<-chan struct{}, error) {
+	notice := make(chan struct{}, 1)
+	last, _ := os.Stat(f.path)
+	go func() {
+		defer close(notice)
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(f.path)
+			if err != nil {
+				continue
+			}
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				notify(notice)
+			}
+		}
+	}()
+	return notice, nil
+}
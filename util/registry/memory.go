@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory is a registry in the process memory, eg: for tests or the applications of RunComposite.
+type Memory struct {
+	mutex     sync.Mutex
+	instances map[string]map[string]Instance // service => addr => instance
+	watchers  map[string]map[chan struct{}]struct{}
+}
+
+// NewMemory returns an empty memory registry.
+func NewMemory() *Memory {
+	return &Memory{
+		instances: map[string]map[string]Instance{},
+		watchers:  map[string]map[chan struct{}]struct{}{},
+	}
+}
+
+func (m *Memory) Register(ctx context.Context, ins Instance) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.instances[ins.Service] == nil {
+		m.instances[ins.Service] = map[string]Instance{}
+	}
+	m.instances[ins.Service][ins.Addr()] = ins
+	m.notifyLocked(ins.Service)
+	return nil
+}
+
+func (m *Memory) Deregister(ctx context.Context, ins Instance) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.instances[ins.Service][ins.Addr()]; !ok {
+		return nil
+	}
+	delete(m.instances[ins.Service], ins.Addr())
+	m.notifyLocked(ins.Service)
+	return nil
+}
+
+// List returns the instances of service sorted by address.
+func (m *Memory) List(ctx context.Context, service string) ([]Instance, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	result := make([]Instance, 0, len(m.instances[service]))
+	for _, ins := range m.instances[service] {
+		result = append(result, ins)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Addr() < result[j].Addr()
+	})
+	return result, nil
+}
+
+func (m *Memory) Watch(ctx context.Context, service string) (<-chan struct{}, error) {
+	notice := make(chan struct{}, 1)
+	m.mutex.Lock()
+	if m.watchers[service] == nil {
+		m.watchers[service] = map[chan struct{}]struct{}{}
+	}
+	m.watchers[service][notice] = struct{}{}
+	m.mutex.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.mutex.Lock()
+		delete(m.watchers[service], notice)
+		m.mutex.Unlock()
+		close(notice)
+	}()
+	return notice, nil
+}
+
+func (m *Memory) notifyLocked(service string) {
+	for notice := range m.watchers[service] {
+		notify(notice)
+	}
+}
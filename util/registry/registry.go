@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+	ProtocolH2C  = "h2c" // http2 without tls
+	ProtocolH2   = "h2"  // http2 with tls
+)
+
+// Instance is a registered instance of a service.
+type Instance struct {
+	Service          string            `json:"service"`
+	Kind             string            `json:"kind,omitempty"` // eg: gRPC Http
+	IP               string            `json:"ip"`
+	Port             string            `json:"port"`
+	Hostname         string            `json:"hostname,omitempty"` // host of the process, tells the instances of the same address apart
+	Version          string            `json:"version,omitempty"`  // app version
+	FrameworkVersion string            `json:"framework_version,omitempty"`
+	BuildCommit      string            `json:"build_commit,omitempty"`
+	Zone             string            `json:"zone,omitempty"`
+	Region           string            `json:"region,omitempty"`
+	Weight           int               `json:"weight,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Protocol         string            `json:"protocol,omitempty"` // eg: grpc http h2c
+	StartTime        string            `json:"start_time,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	LastModified     string            `json:"last_modified,omitempty"`
+}
+
+// Addr returns the dial address of the instance, the service name is used if IP is empty.
+func (i Instance) Addr() string {
+	host := i.IP
+	if host == "" {
+		host = i.Service
+	}
+	return net.JoinHostPort(host, i.Port)
+}
+
+// Registry registers the service instances and discovers them.
+type Registry interface {
+	// Register registers ins, it is kept registered until Deregister
+	Register(ctx context.Context, ins Instance) error
+	// Deregister removes ins
+	Deregister(ctx context.Context, ins Instance) error
+	// List returns the instances of service, empty without error if there is none
+	List(ctx context.Context, service string) ([]Instance, error)
+	// Watch notices the changes of the instances of service, the channel is closed when ctx is done
+	Watch(ctx context.Context, service string) (<-chan struct{}, error)
+}
+
+var (
+	defaultMutex    sync.RWMutex
+	defaultRegistry Registry
+)
+
+// SetDefault sets the registry used by the framework to register the application and resolve the rpc services,
+// it should be called before Run*Application, otherwise the framework picks one by config section kelvins-registry.
+func SetDefault(r Registry) {
+	defaultMutex.Lock()
+	defaultRegistry = r
+	defaultMutex.Unlock()
+}
+
+// Default returns the registry set by SetDefault, nil if not set.
+func Default() Registry {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultRegistry
+}
+
+// notify sends a notice without blocking, a pending notice is enough for the watcher to list again.
+func notify(notice chan struct{}) {
+	select {
+	case notice <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewMemory()
+	notice, err := m.Watch(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ins := Instance{Service: "user", IP: "10.0.0.1", Port: "58001"}
+	if err := m.Register(ctx, ins); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-notice:
+	case <-time.After(time.Second):
+		t.Fatal("no notice after Register")
+	}
+	list, _ := m.List(ctx, "user")
+	if len(list) != 1 || list[0].Addr() != "10.0.0.1:58001" {
+		t.Fatalf("List = %+v", list)
+	}
+	if list, _ := m.List(ctx, "order"); len(list) != 0 {
+		t.Fatalf("List other service = %+v", list)
+	}
+
+	if err := m.Deregister(ctx, ins); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-notice:
+	case <-time.After(time.Second):
+		t.Fatal("no notice after Deregister")
+	}
+	if list, _ := m.List(ctx, "user"); len(list) != 0 {
+		t.Fatalf("List after Deregister = %+v", list)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-notice:
+		if ok {
+			t.Fatal("notice is not closed after ctx done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notice is not closed after ctx done")
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+	err = ioutil.WriteFile(path, []byte(`{"user": [{"ip": "127.0.0.1", "port": "58001"}, {"port": "58002"}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFile(path, 10*time.Millisecond)
+	list, err := f.List(context.Background(), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Addr() != "127.0.0.1:58001" || list[1].Addr() != "user:58002" {
+		t.Fatalf("List = %+v", list)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notice, _ := f.Watch(ctx, "user")
+	err = ioutil.WriteFile(path, []byte(`{"user": []}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-notice:
+	case <-time.After(time.Second):
+		t.Fatal("no notice after the file changed")
+	}
+}
+
+type failRegistry struct {
+	*Memory
+	fail bool
+}
+
+func (f *failRegistry) List(ctx context.Context, service string) ([]Instance, error) {
+	if f.fail {
+		return nil, errors.New("registry unavailable")
+	}
+	return f.Memory.List(ctx, service)
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+	ctx := context.Background()
+
+	r := &failRegistry{Memory: NewMemory()}
+	_ = r.Register(ctx, Instance{Service: "user", IP: "10.0.0.1", Port: "58001"})
+	c := NewCache(r, path)
+	if list, err := c.List(ctx, "user"); err != nil || len(list) != 1 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+
+	r.fail = true
+	if list, err := c.List(ctx, "user"); err != nil || len(list) != 1 || list[0].Addr() != "10.0.0.1:58001" {
+		t.Fatalf("List during outage = %+v, %v", list, err)
+	}
+	if _, err := c.List(ctx, "order"); err == nil {
+		t.Fatal("List of a service never listed should fail during outage")
+	}
+
+	// warm start from the snapshot
+	restarted := NewCache(&failRegistry{Memory: NewMemory(), fail: true}, path)
+	if list, err := restarted.List(ctx, "user"); err != nil || len(list) != 1 || list[0].Addr() != "10.0.0.1:58001" {
+		t.Fatalf("List from snapshot = %+v, %v", list, err)
+	}
+}
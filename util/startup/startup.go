@@ -3,6 +3,7 @@ package startup
 import (
 	"flag"
 	"fmt"
+	"gitee.com/kelvins-io/kelvins/internal/config"
 	"gitee.com/kelvins-io/kelvins/internal/logging"
 	"io/ioutil"
 	"os"
@@ -15,10 +16,11 @@ const (
 	startUpStart   startUpType = "start"
 	startUpReStart startUpType = "restart"
 	startUpStop    startUpType = "stop"
+	startUpConfig  startUpType = "config"
 )
 
 var (
-	control = flag.String("s", string(startUpStart), "control cmd eg: start，stop，restart")
+	control = flag.String("s", string(startUpStart), "control cmd eg: start，stop，restart，config")
 )
 
 func ParseCliCommand(pidFile string) (next bool, err error) {
@@ -32,6 +34,10 @@ func ParseCliCommand(pidFile string) (next bool, err error) {
 			err = fmt.Errorf("process pid file already exist")
 		}
 		return
+	case startUpConfig:
+		next = false
+		err = printConfig()
+		return
 	case startUpReStart:
 	case startUpStop:
 	default:
@@ -48,6 +54,22 @@ func ParseCliCommand(pidFile string) (next bool, err error) {
 	return execProcessCmd(pid, cmd)
 }
 
+// IsConfigCommand reports whether the process is started by -s config, which prints the config and exits.
+func IsConfigCommand() bool {
+	flag.Parse()
+	return startUpType(*control) == startUpConfig
+}
+
+// printConfig prints the effective config as JSON, sensitive values are masked.
+func printConfig() error {
+	data, err := config.DumpJSON()
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
 func parsePidFile(pidFile string) (pid int, err error) {
 	_, err = os.Stat(pidFile)
 	if err != nil {
@@ -1,11 +1,14 @@
 package kelvins
 
 import (
+	"context"
+
 	"gitee.com/kelvins-io/common/event"
 	"gitee.com/kelvins-io/common/log"
 	"gitee.com/kelvins-io/common/queue"
 	"gitee.com/kelvins-io/g2cache"
 	"gitee.com/kelvins-io/kelvins/config/setting"
+	"gitee.com/kelvins-io/kelvins/internal/vars"
 	"gitee.com/kelvins-io/kelvins/util/goroutine"
 	"github.com/gomodule/redigo/redis"
 	"github.com/jinzhu/gorm"
@@ -120,6 +123,18 @@ var MongoDBClient *qmgo.QmgoClient
 // GPoolSetting is maps config section "kelvins-gpool" May be nil
 var GPoolSetting *setting.GPoolSettingS
 
+// ConfigSetting is maps config section "kelvins-config" May be nil
+var ConfigSetting *setting.ConfigSettingS
+
+// ReadinessSetting is maps config section "kelvins-readiness" May be nil
+var ReadinessSetting *setting.ReadinessSettingS
+
+// AdminSetting is maps config section "kelvins-admin" May be nil
+var AdminSetting *setting.AdminSettingS
+
+// RegistrySetting is maps config section "kelvins-registry" May be nil
+var RegistrySetting *setting.RegistrySettingS
+
 // GPool is goroutine pool，close by Framework exit May be nil
 var GPool *goroutine.Pool
 
@@ -132,6 +147,11 @@ var AppName string
 // AppCloseCh is app shutdown notice，close by Framework exit; user only read
 var AppCloseCh <-chan struct{}
 
+// AppContext returns the root context of app, it is cancelled when app begins to stop the servers on shutdown
+func AppContext() context.Context {
+	return vars.AppContext
+}
+
 // GRPCAppInstance is *GRPCApplication instance May be nil
 var GRPCAppInstance *GRPCApplication
 
@@ -143,3 +163,6 @@ var QueueAppInstance *QueueApplication
 
 // HttpAppInstance is *HTTPApplication instance May be nil
 var HttpAppInstance *HTTPApplication
+
+// WorkerAppInstance is *WorkerApplication instance May be nil
+var WorkerAppInstance *WorkerApplication